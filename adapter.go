@@ -0,0 +1,250 @@
+package grpcpool
+
+import (
+	"context"
+	"errors"
+	"io"
+	"sync"
+
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/metadata"
+	"google.golang.org/grpc/peer"
+	"google.golang.org/grpc/status"
+)
+
+// AsClientConn returns a grpc.ClientConnInterface backed by the pool, so that
+// generated grpc clients can be used directly on top of it. Every RPC checks
+// a client out of the pool for its duration: unary calls return it as soon as
+// they complete, streams once RecvMsg returns an error (io.EOF included), the
+// single response of a stream without server streaming is received, SendMsg
+// or Header fail, or their context is done
+func (p *Pool) AsClientConn() grpc.ClientConnInterface {
+	return &adapter{pool: p}
+}
+
+type adapter struct {
+	pool *Pool
+}
+
+// Invoke performs a unary RPC on a client from the pool
+func (a *adapter) Invoke(ctx context.Context, method string, args, reply any,
+	opts ...grpc.CallOption) error {
+	return a.pool.invoke(ctx, method, args, reply, false, opts)
+}
+
+// Do performs a unary RPC on a client from the pool, like the AsClientConn
+// adapter, for call sites doing a single RPC per checkout. The client is
+// returned to the pool once the RPC completes, and marked unhealthy first if
+// it failed with codes.Unavailable, which tells the connection couldn't be
+// used, so that the next Get dials a new one
+func (p *Pool) Do(ctx context.Context, method string, req, reply any,
+	opts ...grpc.CallOption) error {
+	return p.invoke(ctx, method, req, reply, true, opts)
+}
+
+// invoke performs a unary RPC on a client from the pool, marking the client
+// unhealthy if the RPC tells so and unhealthyOnCode is set
+func (p *Pool) invoke(ctx context.Context, method string, args, reply any,
+	unhealthyOnCode bool, opts []grpc.CallOption) error {
+	ctx, cancel := p.rpcContext(ctx)
+	defer cancel()
+
+	conn, err := p.Get(ctx)
+	if err != nil {
+		return err
+	}
+	defer conn.Close()
+
+	if err := conn.rateLimit(ctx); err != nil {
+		return err
+	}
+	opts = p.callOptions(opts)
+	var addr peer.Peer
+	if p.resolve != nil {
+		opts = append(opts[:len(opts):len(opts)], grpc.Peer(&addr))
+	}
+	err = conn.Invoke(ctx, method, args, reply, opts...)
+	conn.RecordError(err)
+	if addr.Addr != nil {
+		conn.NoteAddr(addr.Addr.String())
+	}
+	if unhealthyOnCode && status.Code(err) == codes.Unavailable {
+		conn.Unhealthy()
+	}
+	return err
+}
+
+// NewStream starts a stream on a client from the pool, which is returned when
+// the stream ends
+func (a *adapter) NewStream(ctx context.Context, desc *grpc.StreamDesc,
+	method string, opts ...grpc.CallOption) (grpc.ClientStream, error) {
+	ctx, cancel := a.pool.rpcContext(ctx)
+	conn, err := a.pool.Get(ctx)
+	if err != nil {
+		cancel()
+		return nil, err
+	}
+	if err := conn.rateLimit(ctx); err != nil {
+		conn.Close()
+		cancel()
+		return nil, err
+	}
+	stream, err := conn.NewStream(ctx, desc, method, a.pool.callOptions(opts)...)
+	if err != nil {
+		conn.RecordError(err)
+		conn.Close()
+		cancel()
+		return nil, err
+	}
+
+	return newPooledStream(ctx, stream, conn, cancel, desc.ServerStreams), nil
+}
+
+// GetStream checks a client out of the pool and starts a stream on it with
+// start. The stream returned holds the client until it's over: the client is
+// returned to the pool as soon as RecvMsg returns an error (io.EOF included),
+// SendMsg or Header fail, ctx is done, or the returned cleanup function is
+// called, whichever comes first. Calling cleanup once done with the stream,
+// e.g. with defer, is safe and guarantees the client isn't leaked, notably
+// for a stream without server streaming, which RecvMsg doesn't end with an
+// error. cleanup doesn't end the stream itself, which is up to the context
+// given to start
+func (p *Pool) GetStream(ctx context.Context,
+	start func(conn *grpc.ClientConn) (grpc.ClientStream, error)) (grpc.ClientStream, func(), error) {
+	conn, err := p.Get(ctx)
+	if err != nil {
+		return nil, nil, err
+	}
+	started := false
+	defer func() {
+		// start panicked, the client is returned as the panic goes on
+		if !started {
+			conn.Close()
+		}
+	}()
+	stream, err := start(conn.ClientConn)
+	started = true
+	if err != nil {
+		conn.RecordError(err)
+		conn.Close()
+		return nil, nil, err
+	}
+
+	s := newPooledStream(ctx, stream, conn, func() {}, true)
+	return s, s.release, nil
+}
+
+// RunWithConn checks a client out of the pool, calls fn with it, and returns
+// it to the pool once fn returns, or panics. The error fn returns is recorded
+// for WithAutoEvictOnErrorRate, then returned
+func (p *Pool) RunWithConn(ctx context.Context, fn func(conn *grpc.ClientConn) error) error {
+	conn, err := p.Get(ctx)
+	if err != nil {
+		return err
+	}
+	defer conn.Close()
+
+	err = fn(conn.ClientConn)
+	conn.RecordError(err)
+	return err
+}
+
+// rpcContext derives the context of an RPC performed through the adapter,
+// applying the default RPC timeout if ctx has no deadline
+func (p *Pool) rpcContext(ctx context.Context) (context.Context, context.CancelFunc) {
+	if p.defaultRPCTimeout <= 0 {
+		return ctx, func() {}
+	}
+	if _, ok := ctx.Deadline(); ok {
+		return ctx, func() {}
+	}
+	return context.WithTimeout(ctx, p.defaultRPCTimeout)
+}
+
+// callOptions prepends the pool default call options to opts, so that the
+// ones given for a specific call take precedence
+func (p *Pool) callOptions(opts []grpc.CallOption) []grpc.CallOption {
+	if len(p.defaultCallOptions) == 0 {
+		return opts
+	}
+	all := make([]grpc.CallOption, 0, len(p.defaultCallOptions)+len(opts))
+	all = append(all, p.defaultCallOptions...)
+	return append(all, opts...)
+}
+
+// pooledStream is a stream holding a client from the pool until it ends
+type pooledStream struct {
+	grpc.ClientStream
+	conn          *ClientConn
+	cancel        context.CancelFunc
+	serverStreams bool
+	once          sync.Once
+	done          chan struct{}
+}
+
+// newPooledStream wraps stream so that it returns conn to the pool and calls
+// cancel once over. Without serverStreams, the stream is over as soon as its
+// single response is received
+func newPooledStream(ctx context.Context, stream grpc.ClientStream,
+	conn *ClientConn, cancel context.CancelFunc, serverStreams bool) *pooledStream {
+	s := &pooledStream{
+		ClientStream:  stream,
+		conn:          conn,
+		cancel:        cancel,
+		serverStreams: serverStreams,
+		done:          make(chan struct{}),
+	}
+	go func() {
+		select {
+		case <-ctx.Done():
+			s.release()
+		case <-s.done:
+		}
+	}()
+	return s
+}
+
+// RecvMsg receives a message from the stream, returning the client to the
+// pool once the stream is over
+func (s *pooledStream) RecvMsg(m any) error {
+	err := s.ClientStream.RecvMsg(m)
+	if err != nil || !s.serverStreams {
+		s.release()
+	}
+	return err
+}
+
+// SendMsg sends a message on the stream, returning the client to the pool if
+// the stream failed. io.EOF only tells the stream ended, its status being up
+// to RecvMsg
+func (s *pooledStream) SendMsg(m any) error {
+	err := s.ClientStream.SendMsg(m)
+	if err != nil && !errors.Is(err, io.EOF) {
+		s.release()
+	}
+	return err
+}
+
+// Header returns the header metadata of the stream, returning the client to
+// the pool if the stream failed
+func (s *pooledStream) Header() (metadata.MD, error) {
+	md, err := s.ClientStream.Header()
+	if err != nil && !errors.Is(err, io.EOF) {
+		s.release()
+	}
+	return md, err
+}
+
+func (s *pooledStream) release() {
+	s.once.Do(func() {
+		close(s.done)
+		if s.conn.pool.resolve != nil {
+			if addr, ok := peer.FromContext(s.ClientStream.Context()); ok {
+				s.conn.NoteAddr(addr.Addr.String())
+			}
+		}
+		s.conn.Close()
+		s.cancel()
+	})
+}
@@ -0,0 +1,249 @@
+package grpcpool
+
+import (
+	"context"
+	"net"
+	"testing"
+	"time"
+
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/credentials/insecure"
+	"google.golang.org/grpc/health"
+	healthpb "google.golang.org/grpc/health/grpc_health_v1"
+	"google.golang.org/grpc/status"
+	"google.golang.org/grpc/test/bufconn"
+)
+
+// newTestServer starts an in-memory grpc server exposing the health service
+// and returns a factory dialing it
+func newTestServer(t *testing.T) Factory {
+	t.Helper()
+	lis := bufconn.Listen(1 << 20)
+	srv := grpc.NewServer()
+	healthpb.RegisterHealthServer(srv, health.NewServer())
+	go srv.Serve(lis)
+	t.Cleanup(srv.Stop)
+
+	return func() (*grpc.ClientConn, error) {
+		return grpc.NewClient("passthrough:///bufnet",
+			grpc.WithContextDialer(func(ctx context.Context, _ string) (net.Conn, error) {
+				return lis.DialContext(ctx)
+			}),
+			grpc.WithTransportCredentials(insecure.NewCredentials()))
+	}
+}
+
+func TestAsClientConn(t *testing.T) {
+	p, err := New(newTestServer(t), 1, 2, 0)
+	if err != nil {
+		t.Errorf("The pool returned an error: %s", err.Error())
+	}
+	defer p.Close()
+
+	client := healthpb.NewHealthClient(p.AsClientConn())
+	resp, err := client.Check(context.Background(), &healthpb.HealthCheckRequest{})
+	if err != nil {
+		t.Errorf("Check returned an error: %s", err.Error())
+	} else if resp.Status != healthpb.HealthCheckResponse_SERVING {
+		t.Errorf("The status was %s but should be SERVING", resp.Status)
+	}
+	if a := p.Available(); a != 2 {
+		t.Errorf("The pool available was %d but should be 2", a)
+	}
+
+	// The stream returns the client when it's cancelled
+	ctx, cancel := context.WithCancel(context.Background())
+	stream, err := client.Watch(ctx, &healthpb.HealthCheckRequest{})
+	if err != nil {
+		t.Errorf("Watch returned an error: %s", err.Error())
+	}
+	if _, err := stream.Recv(); err != nil {
+		t.Errorf("Recv returned an error: %s", err.Error())
+	}
+	if a := p.Available(); a != 1 {
+		t.Errorf("The pool available was %d but should be 1", a)
+	}
+	cancel()
+	if _, err := stream.Recv(); err == nil {
+		t.Error("Recv should have returned an error after cancel")
+	}
+	if a := p.Available(); a != 2 {
+		t.Errorf("The pool available was %d but should be 2", a)
+	}
+}
+
+func TestWithCompressor(t *testing.T) {
+	p, err := New(newTestServer(t), 1, 1, 0, WithCompressor("unregistered"))
+	if err != nil {
+		t.Errorf("The pool returned an error: %s", err.Error())
+	}
+	defer p.Close()
+
+	// The compressor isn't registered so the call must fail, proving the
+	// option was applied
+	client := healthpb.NewHealthClient(p.AsClientConn())
+	if _, err := client.Check(context.Background(), &healthpb.HealthCheckRequest{}); err == nil {
+		t.Error("Check with an unregistered compressor should have failed")
+	}
+}
+
+func TestWithDefaultRPCTimeout(t *testing.T) {
+	p, err := New(newTestServer(t), 1, 1, 0,
+		WithDefaultRPCTimeout(20*time.Millisecond))
+	if err != nil {
+		t.Errorf("The pool returned an error: %s", err.Error())
+	}
+	defer p.Close()
+
+	// Watch never ends by itself, so the stream must hit the default timeout
+	client := healthpb.NewHealthClient(p.AsClientConn())
+	stream, err := client.Watch(context.Background(), &healthpb.HealthCheckRequest{})
+	if err != nil {
+		t.Errorf("Watch returned an error: %s", err.Error())
+	}
+	stream.Recv()
+	if _, err := stream.Recv(); status.Code(err) != codes.DeadlineExceeded {
+		t.Errorf("Expected a deadline exceeded error but got \"%v\"", err)
+	}
+	if a := p.Available(); a != 1 {
+		t.Errorf("The pool available was %d but should be 1", a)
+	}
+
+	// An existing deadline isn't overridden
+	ctx, cancel := context.WithTimeout(context.Background(), time.Hour)
+	defer cancel()
+	stream, err = client.Watch(ctx, &healthpb.HealthCheckRequest{})
+	if err != nil {
+		t.Errorf("Watch returned an error: %s", err.Error())
+	}
+	if deadline, _ := stream.Context().Deadline(); time.Until(deadline) < time.Minute {
+		t.Errorf("The stream deadline was overridden to %s", deadline)
+	}
+}
+
+func TestGetStream(t *testing.T) {
+	p, err := New(newTestServer(t), 1, 1, 0)
+	if err != nil {
+		t.Errorf("The pool returned an error: %s", err.Error())
+	}
+	defer p.Close()
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	stream, cleanup, err := p.GetStream(ctx,
+		func(conn *grpc.ClientConn) (grpc.ClientStream, error) {
+			return healthpb.NewHealthClient(conn).Watch(ctx,
+				&healthpb.HealthCheckRequest{})
+		})
+	if err != nil {
+		t.Errorf("GetStream returned an error: %s", err.Error())
+	}
+	var resp healthpb.HealthCheckResponse
+	if err := stream.RecvMsg(&resp); err != nil {
+		t.Errorf("RecvMsg returned an error: %s", err.Error())
+	}
+	if a := p.Available(); a != 0 {
+		t.Errorf("The pool available was %d but should be 0", a)
+	}
+	cleanup()
+	cleanup()
+	if a := p.Available(); a != 1 {
+		t.Errorf("The pool available was %d but should be 1", a)
+	}
+}
+
+func TestAsClientConnClientStream(t *testing.T) {
+	p, err := New(newTestServer(t), 1, 1, 0)
+	if err != nil {
+		t.Errorf("The pool returned an error: %s", err.Error())
+	}
+	defer p.Close()
+
+	// Without server streaming, grpc ends the stream on the first response:
+	// RecvMsg never returns io.EOF
+	desc := &grpc.StreamDesc{ClientStreams: true}
+	stream, err := p.AsClientConn().NewStream(context.Background(), desc,
+		"/grpc.health.v1.Health/Check")
+	if err != nil {
+		t.Errorf("NewStream returned an error: %s", err.Error())
+	}
+	if err := stream.SendMsg(&healthpb.HealthCheckRequest{}); err != nil {
+		t.Errorf("SendMsg returned an error: %s", err.Error())
+	}
+	if err := stream.CloseSend(); err != nil {
+		t.Errorf("CloseSend returned an error: %s", err.Error())
+	}
+	var resp healthpb.HealthCheckResponse
+	if err := stream.RecvMsg(&resp); err != nil {
+		t.Errorf("RecvMsg returned an error: %s", err.Error())
+	}
+	if a := p.Available(); a != 1 {
+		t.Errorf("The pool available was %d but should be 1", a)
+	}
+}
+
+func TestRunWithConnPanic(t *testing.T) {
+	p, err := New(dialTestClient, 1, 1, 0)
+	if err != nil {
+		t.Errorf("The pool returned an error: %s", err.Error())
+	}
+	defer p.Close()
+
+	if err := p.RunWithConn(context.Background(), func(*grpc.ClientConn) error {
+		return nil
+	}); err != nil {
+		t.Errorf("RunWithConn returned an error: %s", err.Error())
+	}
+
+	expectPanic(t, "RunWithConn", func() {
+		p.RunWithConn(context.Background(), func(*grpc.ClientConn) error {
+			panic("boom")
+		})
+	})
+	if a := p.Available(); a != 1 {
+		t.Errorf("The pool available was %d but should be 1", a)
+	}
+
+	expectPanic(t, "GetStream", func() {
+		p.GetStream(context.Background(),
+			func(*grpc.ClientConn) (grpc.ClientStream, error) {
+				panic("boom")
+			})
+	})
+	if a := p.Available(); a != 1 {
+		t.Errorf("The pool available was %d but should be 1", a)
+	}
+}
+
+func TestDo(t *testing.T) {
+	p, err := New(newTestServer(t), 1, 1, 0)
+	if err != nil {
+		t.Errorf("The pool returned an error: %s", err.Error())
+	}
+	defer p.Close()
+
+	var resp healthpb.HealthCheckResponse
+	if err := p.Do(context.Background(), healthpb.Health_Check_FullMethodName,
+		&healthpb.HealthCheckRequest{}, &resp); err != nil {
+		t.Errorf("Do returned an error: %s", err.Error())
+	}
+	if resp.Status != healthpb.HealthCheckResponse_SERVING {
+		t.Errorf("The status was %s but should be SERVING", resp.Status)
+	}
+
+	// A client which can't reach its backend is recycled
+	down, err := New(dialTestClient, 1, 1, 0)
+	if err != nil {
+		t.Errorf("The pool returned an error: %s", err.Error())
+	}
+	defer down.Close()
+	err = down.Do(context.Background(), healthpb.Health_Check_FullMethodName,
+		&healthpb.HealthCheckRequest{}, &resp)
+	if status.Code(err) != codes.Unavailable {
+		t.Errorf("The code was %s but should be %s", status.Code(err), codes.Unavailable)
+	}
+	if s := down.Stats(); s.Unhealthy != 1 || s.Available != 1 {
+		t.Errorf("The stats were %+v", s)
+	}
+}
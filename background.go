@@ -0,0 +1,113 @@
+package grpcpool
+
+import (
+	"time"
+)
+
+// runEvery calls fn every interval in the background, until the pool is
+// closed. Close waits for fn to return before closing the clients
+func (p *Pool) runEvery(interval time.Duration, fn func()) {
+	p.wg.Add(1)
+	go func() {
+		defer p.wg.Done()
+
+		ticker := time.NewTicker(interval)
+		defer ticker.Stop()
+
+		for {
+			select {
+			case <-ticker.C:
+				fn()
+			case <-p.ctx.Done():
+				return
+			}
+		}
+	}()
+}
+
+// sweep passes every idle client, placeholders included, through fn and puts
+// back the client it returns in the pool. Clients checked out during the
+// sweep are left alone. The read lock is held so that the pool can't be
+// closed under it
+func (p *Pool) sweep(fn func(ClientConn) ClientConn) {
+	p.mu.RLock()
+	defer p.mu.RUnlock()
+
+	if p.clients == nil {
+		return
+	}
+	for i := len(p.clients); i > 0; i-- {
+		var client ClientConn
+		select {
+		case client = <-p.clients:
+		default:
+			return
+		}
+		client = fn(client)
+		if !p.send(client) && client.ClientConn != nil {
+			// Can't happen as we just took a slot, but never leak a client
+			p.closeConn(client.ClientConn)
+		}
+	}
+}
+
+// WithBackgroundEviction moves the recycling of the stale idle clients, like
+// the ones idle for longer than the idle timeout, from Get to a sweep of the
+// pool every interval, so that Get doesn't pay for closing a client and
+// dialing a new one unless it takes a placeholder. The tradeoff is staleness:
+// between two sweeps, Get can hand out a client up to interval past its idle
+// timeout. The clients checked out are left alone, the sweep only sees the
+// idle ones
+func WithBackgroundEviction(interval time.Duration) Option {
+	return func(p *Pool) {
+		p.evictInterval = interval
+	}
+}
+
+// evictIdle recycles the stale idle clients, leaving placeholders in their
+// slots
+func (p *Pool) evictIdle() {
+	cfg := p.currentConfig()
+	p.sweep(func(client ClientConn) ClientConn {
+		if client.ClientConn == nil {
+			return client
+		}
+		if reason := p.recycleReason(client, cfg); reason != ReasonNone {
+			p.recycle(client, reason)
+			return ClientConn{pool: p}
+		}
+		return client
+	})
+}
+
+// WithPoolTTL closes the pool once ttl has elapsed since its creation, so
+// that a temporary pool forgotten by its owner doesn't linger. onExpired, if
+// not nil, is called after the pool was closed by the TTL. Closing the pool
+// before the TTL cancels it, and onExpired is never called
+func WithPoolTTL(ttl time.Duration, onExpired func()) Option {
+	return func(p *Pool) {
+		p.ttl = ttl
+		p.onExpired = onExpired
+	}
+}
+
+// closeAfterTTL closes the pool in the background once its TTL has elapsed
+func (p *Pool) closeAfterTTL() {
+	p.wg.Add(1)
+	go func() {
+		timer := time.NewTimer(p.ttl)
+		defer timer.Stop()
+
+		select {
+		case <-timer.C:
+		case <-p.ctx.Done():
+			p.wg.Done()
+			return
+		}
+		// Close waits for the background tasks, this one included
+		p.wg.Done()
+		if p.close() && p.onExpired != nil {
+			p.onExpired()
+		}
+	}()
+}
@@ -0,0 +1,121 @@
+package grpcpool
+
+import (
+	"context"
+	"runtime"
+	"sync"
+	"testing"
+	"time"
+)
+
+func TestCloseWithBackgroundTasks(t *testing.T) {
+	before := runtime.NumGoroutine()
+
+	p, err := New(dialTestClient, 2, 4, time.Millisecond,
+		WithAutoEvictOnErrorRate(0, time.Millisecond),
+		WithPoolTTL(time.Hour, nil))
+	if err != nil {
+		t.Errorf("The pool returned an error: %s", err.Error())
+	}
+
+	// Keep clients moving in and out of the pool while it's closed
+	var wg sync.WaitGroup
+	for i := 0; i < 4; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for !p.IsClosed() {
+				client, err := p.Get(context.Background())
+				if err != nil {
+					continue
+				}
+				client.RecordError(context.Canceled)
+				client.Close()
+			}
+		}()
+	}
+	time.Sleep(20 * time.Millisecond)
+	p.Close()
+	wg.Wait()
+
+	if a := p.Available(); a != 0 {
+		t.Errorf("The pool available was %d but should be 0", a)
+	}
+	if s := p.Stats(); s.Created != s.Closed {
+		t.Errorf("The pool created %d clients but closed %d", s.Created, s.Closed)
+	}
+
+	deadline := time.Now().Add(time.Second)
+	for runtime.NumGoroutine() > before && time.Now().Before(deadline) {
+		time.Sleep(5 * time.Millisecond)
+	}
+	if n := runtime.NumGoroutine(); n > before {
+		t.Errorf("%d goroutines were leaked", n-before)
+	}
+}
+
+func TestPoolTTL(t *testing.T) {
+	expired := make(chan struct{})
+	p, err := New(dialTestClient, 1, 1, 0,
+		WithPoolTTL(10*time.Millisecond, func() { close(expired) }))
+	if err != nil {
+		t.Errorf("The pool returned an error: %s", err.Error())
+	}
+
+	select {
+	case <-expired:
+	case <-time.After(time.Second):
+		t.Fatal("The pool TTL didn't fire")
+	}
+	if !p.IsClosed() {
+		t.Error("The pool should be closed once its TTL elapsed")
+	}
+
+	// Closing the pool first cancels the TTL
+	p, err = New(dialTestClient, 1, 1, 0,
+		WithPoolTTL(10*time.Millisecond, func() { t.Error("The TTL fired after Close") }))
+	if err != nil {
+		t.Errorf("The pool returned an error: %s", err.Error())
+	}
+	p.Close()
+	time.Sleep(20 * time.Millisecond)
+}
+
+func TestWithBackgroundEviction(t *testing.T) {
+	p, err := New(dialTestClient, 1, 1, time.Millisecond, WithBackgroundEviction(time.Hour))
+	if err != nil {
+		t.Errorf("The pool returned an error: %s", err.Error())
+	}
+	client, err := p.Get(context.Background())
+	if err != nil {
+		t.Errorf("Get returned an error: %s", err.Error())
+	}
+	id := client.ID()
+	client.Close()
+	time.Sleep(5 * time.Millisecond)
+
+	// Get hands out the stale client, left to the sweep
+	client, err = p.Get(context.Background())
+	if err != nil {
+		t.Errorf("Get returned an error: %s", err.Error())
+	}
+	if client.ID() != id {
+		t.Errorf("Get shouldn't have recycled the client %d", id)
+	}
+	client.Close()
+	p.Close()
+
+	p, err = New(dialTestClient, 1, 1, 10*time.Millisecond,
+		WithBackgroundEviction(5*time.Millisecond))
+	if err != nil {
+		t.Errorf("The pool returned an error: %s", err.Error())
+	}
+	defer p.Close()
+	deadline := time.Now().Add(time.Second)
+	for p.Stats().Evicted == 0 && time.Now().Before(deadline) {
+		time.Sleep(5 * time.Millisecond)
+	}
+	if s := p.Stats(); s.Evicted != 1 || s.Available != 1 {
+		t.Errorf("The stats were %+v", s)
+	}
+}
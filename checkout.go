@@ -0,0 +1,124 @@
+package grpcpool
+
+import (
+	"runtime/debug"
+	"sort"
+	"time"
+
+	"google.golang.org/grpc"
+)
+
+// ConnInfo describes a client of the pool
+type ConnInfo struct {
+	// ID identifies the client within its pool
+	ID uint64 `json:"id"`
+	// Target is the target the client was dialed to
+	Target string `json:"target"`
+	// Labels are the labels given to the client by its LabeledFactory
+	Labels map[string]string `json:"labels,omitempty"`
+	// Version is the last backend version noted for the client
+	Version string `json:"version,omitempty"`
+	// CreatedAt is when the client was created
+	CreatedAt time.Time `json:"created_at"`
+	// LastErrorAt is when the last error was recorded for the client, zero
+	// if none was since it was created or since ClientConn.NoteSuccess
+	LastErrorAt time.Time `json:"last_error_at,omitempty"`
+	// CheckedOutAt is when the client was checked out, zero if it's idle
+	CheckedOutAt time.Time `json:"checked_out_at,omitempty"`
+	// Stack is the stack trace of the goroutine which checked the client out,
+	// when recorded with WithCheckoutTracking
+	Stack string `json:"stack,omitempty"`
+}
+
+// checkout records a client handed out to a caller
+type checkout struct {
+	meta  *connMeta
+	at    time.Time
+	stack string
+}
+
+// WithCheckoutTracking makes the pool keep track of the clients checked out,
+// so that CheckedOut can list them, e.g. to diagnose a leak. With stacks, the
+// stack trace of the goroutine checking out each client is recorded too,
+// which makes Get significantly more expensive
+func WithCheckoutTracking(stacks bool) Option {
+	return func(p *Pool) {
+		p.stacks = stacks
+		p.trackCheckouts()
+	}
+}
+
+// trackCheckouts enables the tracking of the checked out clients
+func (p *Pool) trackCheckouts() {
+	if p.outstanding == nil {
+		p.outstanding = make(map[*grpc.ClientConn]checkout)
+	}
+}
+
+// CheckedOut returns the clients currently checked out, oldest checkout
+// first. It returns nil unless WithCheckoutTracking or WithStrictLifecycle is
+// set
+func (p *Pool) CheckedOut() []ConnInfo {
+	if p.outstanding == nil {
+		return nil
+	}
+
+	p.outMu.Lock()
+	infos := make([]ConnInfo, 0, len(p.outstanding))
+	for conn, out := range p.outstanding {
+		info := out.meta.info(conn)
+		info.CheckedOutAt = out.at
+		info.Stack = out.stack
+		infos = append(infos, info)
+	}
+	p.outMu.Unlock()
+
+	sort.Slice(infos, func(i, j int) bool {
+		return infos[i].CheckedOutAt.Before(infos[j].CheckedOutAt)
+	})
+	return infos
+}
+
+// info describes the client conn the metadata belongs to
+func (m *connMeta) info(conn *grpc.ClientConn) ConnInfo {
+	m.mu.Lock()
+	version, lastErrorAt := m.version, m.lastErrorAt
+	m.mu.Unlock()
+
+	return ConnInfo{
+		ID:          m.id,
+		Target:      conn.Target(),
+		Labels:      m.labels,
+		Version:     version,
+		CreatedAt:   m.createdAt,
+		LastErrorAt: lastErrorAt,
+	}
+}
+
+// checkOut records client as handed out to a caller
+func (p *Pool) checkOut(client ClientConn) {
+	out := checkout{
+		meta: client.meta,
+		at:   time.Now(),
+	}
+	if p.stacks {
+		out.stack = string(debug.Stack())
+	}
+
+	p.outMu.Lock()
+	defer p.outMu.Unlock()
+
+	p.outstanding[client.ClientConn] = out
+}
+
+// checkIn removes conn from the checked out clients. In strict mode, it
+// panics if conn wasn't checked out from this pool
+func (p *Pool) checkIn(conn *grpc.ClientConn) {
+	p.outMu.Lock()
+	defer p.outMu.Unlock()
+
+	if _, ok := p.outstanding[conn]; !ok && p.strict {
+		panic("grpc pool: strict lifecycle: ClientConn was not checked out from this pool")
+	}
+	delete(p.outstanding, conn)
+}
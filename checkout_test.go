@@ -0,0 +1,34 @@
+package grpcpool
+
+import (
+	"context"
+	"strings"
+	"testing"
+)
+
+func TestCheckedOut(t *testing.T) {
+	p, err := New(dialTestClient, 2, 2, 0, WithCheckoutTracking(true))
+	if err != nil {
+		t.Errorf("The pool returned an error: %s", err.Error())
+	}
+	defer p.Close()
+
+	cl1, _ := p.Get(context.Background())
+	cl2, _ := p.Get(context.Background())
+	cl1.Close()
+
+	infos := p.CheckedOut()
+	if len(infos) != 1 {
+		t.Fatalf("%d clients were checked out but should be 1", len(infos))
+	}
+	if infos[0].ID != cl2.ID() || infos[0].CheckedOutAt.IsZero() {
+		t.Errorf("The checked out client was %+v", infos[0])
+	}
+	if !strings.Contains(infos[0].Stack, "TestCheckedOut") {
+		t.Errorf("The stack should show the checkout in the test, was %s", infos[0].Stack)
+	}
+	cl2.Close()
+	if infos := p.CheckedOut(); len(infos) != 0 {
+		t.Errorf("%d clients were checked out but should be 0", len(infos))
+	}
+}
@@ -0,0 +1,93 @@
+package grpcpool
+
+import (
+	"errors"
+	"fmt"
+)
+
+// ErrNotForced is the error when CloseWith(CloseForce) can't close the clients
+// checked out, the pool not tracking them
+var ErrNotForced = errors.New("grpc pool: checked out clients not force closed")
+
+// ClosePolicy tells CloseWith what to do with the clients checked out when
+// the pool is closed
+type ClosePolicy int
+
+const (
+	// CloseBlock closes the idle clients, then waits for the clients checked
+	// out to be returned, closing each of them on return. A client never
+	// returned blocks CloseWith forever
+	CloseBlock ClosePolicy = iota
+	// CloseForce closes the idle clients and the clients checked out from
+	// under their holders, failing their in-flight RPCs, and returns
+	// immediately
+	CloseForce
+	// CloseOrphan closes the idle clients and returns immediately, the
+	// clients checked out being closed when returned. It is what Close does
+	CloseOrphan
+)
+
+// CloseWith closes the pool like Close, handling the clients checked out
+// according to policy. Whatever the policy, returning a client to the closed
+// pool returns ErrClosed.
+//
+// The clients checked out are only known to the pool with
+// WithCheckoutTracking or WithStrictLifecycle: without either, CloseForce
+// can't close them. The pool is closed anyway, the clients checked out being
+// closed when returned as with CloseOrphan, and CloseWith returns an error
+// wrapping ErrNotForced with their number
+func (p *Pool) CloseWith(policy ClosePolicy) error {
+	switch policy {
+	case CloseBlock:
+		p.close()
+		for {
+			// The count is checked under returnMu, so that the last client
+			// returned either is counted or finds the wait armed
+			p.returnMu.Lock()
+			if p.checkedOut.Load() <= 0 {
+				p.allReturned = nil
+				p.returnMu.Unlock()
+				return nil
+			}
+			returned := make(chan struct{})
+			p.allReturned = returned
+			p.returnMu.Unlock()
+			<-returned
+		}
+	case CloseForce:
+		if !p.close() {
+			return nil
+		}
+		if p.outstanding == nil {
+			if n := p.checkedOut.Load(); n > 0 {
+				return fmt.Errorf("%w: %d clients checked out", ErrNotForced, n)
+			}
+			return nil
+		}
+		p.outMu.Lock()
+		defer p.outMu.Unlock()
+
+		for conn, out := range p.outstanding {
+			p.closeConn(conn)
+			out.meta.record(EventRecycled, ReasonPoolClosed, nil)
+		}
+	default:
+		p.close()
+	}
+	return nil
+}
+
+// noteReturned counts a client checked out as returned, waking CloseWith up
+// if it's waiting for the last one
+func (p *Pool) noteReturned() {
+	if p.checkedOut.Add(-1) > 0 {
+		return
+	}
+	p.returnMu.Lock()
+	defer p.returnMu.Unlock()
+
+	if p.allReturned != nil {
+		close(p.allReturned)
+		p.allReturned = nil
+	}
+}
@@ -0,0 +1,124 @@
+package grpcpool
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"google.golang.org/grpc/connectivity"
+)
+
+func TestCloseWithBlock(t *testing.T) {
+	p, err := New(dialTestClient, 2, 2, 0)
+	if err != nil {
+		t.Errorf("The pool returned an error: %s", err.Error())
+	}
+
+	client, err := p.Get(context.Background())
+	if err != nil {
+		t.Errorf("Get returned an error: %s", err.Error())
+	}
+	go func() {
+		time.Sleep(20 * time.Millisecond)
+		if err := client.Close(); err != ErrClosed {
+			t.Errorf("Expected error \"%s\" but got \"%v\"", ErrClosed, err)
+		}
+	}()
+
+	start := time.Now()
+	p.CloseWith(CloseBlock)
+	if d := time.Since(start); d < 20*time.Millisecond {
+		t.Errorf("CloseWith returned after %s but should have waited for the client", d)
+	}
+	if s := p.Stats(); s.Created-s.Closed != 0 {
+		t.Errorf("%d clients were left open", s.Created-s.Closed)
+	}
+}
+
+func TestCloseWithForce(t *testing.T) {
+	p, err := New(dialTestClient, 2, 2, 0, WithCheckoutTracking(false))
+	if err != nil {
+		t.Errorf("The pool returned an error: %s", err.Error())
+	}
+
+	client, err := p.Get(context.Background())
+	if err != nil {
+		t.Errorf("Get returned an error: %s", err.Error())
+	}
+	if err := p.CloseWith(CloseForce); err != nil {
+		t.Errorf("CloseWith returned an error: %s", err.Error())
+	}
+	if s := client.GetState(); s != connectivity.Shutdown {
+		t.Errorf("The client checked out was %s but should be %s", s, connectivity.Shutdown)
+	}
+	if err := client.Close(); err != ErrClosed {
+		t.Errorf("Expected error \"%s\" but got \"%v\"", ErrClosed, err)
+	}
+	if s := p.Stats(); s.Closed != s.Created {
+		t.Errorf("%d clients were closed but should be %d", s.Closed, s.Created)
+	}
+}
+
+func TestCloseWithOrphan(t *testing.T) {
+	p, err := New(dialTestClient, 2, 2, 0)
+	if err != nil {
+		t.Errorf("The pool returned an error: %s", err.Error())
+	}
+
+	client, err := p.Get(context.Background())
+	if err != nil {
+		t.Errorf("Get returned an error: %s", err.Error())
+	}
+	p.CloseWith(CloseOrphan)
+	if s := client.GetState(); s == connectivity.Shutdown {
+		t.Errorf("The client checked out shouldn't have been closed")
+	}
+	if err := client.Close(); err != ErrClosed {
+		t.Errorf("Expected error \"%s\" but got \"%v\"", ErrClosed, err)
+	}
+	if s := p.Stats(); s.Created-s.Closed != 0 {
+		t.Errorf("%d clients were left open", s.Created-s.Closed)
+	}
+}
+
+func TestCloseWithBlockFullPool(t *testing.T) {
+	p, err := New(dialTestClient, 1, 1, 0)
+	if err != nil {
+		t.Errorf("The pool returned an error: %s", err.Error())
+	}
+
+	client, err := p.Get(context.Background())
+	if err != nil {
+		t.Errorf("Get returned an error: %s", err.Error())
+	}
+	copied := *client
+	client.Close()
+	// The copy isn't given back, the pool being full: it doesn't count as
+	// returned
+	if err := copied.Close(); err != ErrFullPool {
+		t.Errorf("Expected error \"%s\" but got \"%v\"", ErrFullPool, err)
+	}
+	if n := p.checkedOut.Load(); n != 0 {
+		t.Errorf("%d clients were checked out but should be 0", n)
+	}
+	p.CloseWith(CloseBlock)
+}
+
+func TestCloseWithForceUntracked(t *testing.T) {
+	p, err := New(dialTestClient, 1, 1, 0)
+	if err != nil {
+		t.Errorf("The pool returned an error: %s", err.Error())
+	}
+
+	client, err := p.Get(context.Background())
+	if err != nil {
+		t.Errorf("Get returned an error: %s", err.Error())
+	}
+	if err := p.CloseWith(CloseForce); !errors.Is(err, ErrNotForced) {
+		t.Errorf("Expected error \"%s\" but got \"%v\"", ErrNotForced, err)
+	}
+	if err := client.Close(); err != ErrClosed {
+		t.Errorf("Expected error \"%s\" but got \"%v\"", ErrClosed, err)
+	}
+}
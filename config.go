@@ -0,0 +1,77 @@
+package grpcpool
+
+import (
+	"errors"
+	"fmt"
+	"time"
+)
+
+// ErrImmutableConfig is the error when restoring a configuration changing a
+// setting which can't be changed once the pool is created
+var ErrImmutableConfig = errors.New("grpc pool: the setting cannot be changed at runtime")
+
+// config holds the settings of a pool which can be changed at runtime
+type config struct {
+	idleTimeout      time.Duration
+	queueTimeout     time.Duration
+	dialTimeout      time.Duration
+	preferredVersion string
+}
+
+// ConfigSnapshot is a copy of the configuration of a pool, which can be
+// restored with RestoreConfig, e.g. to roll back a tuning experiment
+type ConfigSnapshot struct {
+	// Capacity can't be changed at runtime, RestoreConfig only checks that it
+	// matches the pool one
+	Capacity int
+
+	IdleTimeout      time.Duration
+	QueueTimeout     time.Duration
+	DialTimeout      time.Duration
+	PreferredVersion string
+}
+
+// currentConfig returns a copy of the runtime settings
+func (p *Pool) currentConfig() config {
+	p.configMu.RLock()
+	defer p.configMu.RUnlock()
+
+	return p.config
+}
+
+// SnapshotConfig returns the current configuration of the pool
+func (p *Pool) SnapshotConfig() ConfigSnapshot {
+	cfg := p.currentConfig()
+	return ConfigSnapshot{
+		Capacity:         p.Capacity(),
+		IdleTimeout:      cfg.idleTimeout,
+		QueueTimeout:     cfg.queueTimeout,
+		DialTimeout:      cfg.dialTimeout,
+		PreferredVersion: cfg.preferredVersion,
+	}
+}
+
+// RestoreConfig applies the settings of snapshot to the pool, taking effect
+// on the following calls to Get. It fails with an error wrapping
+// ErrImmutableConfig, without changing anything, if snapshot has another
+// capacity than the pool
+func (p *Pool) RestoreConfig(snapshot ConfigSnapshot) error {
+	if p.IsClosed() {
+		return ErrClosed
+	}
+	if c := p.Capacity(); snapshot.Capacity != c {
+		return fmt.Errorf("%w: capacity %d instead of %d", ErrImmutableConfig,
+			snapshot.Capacity, c)
+	}
+
+	p.configMu.Lock()
+	defer p.configMu.Unlock()
+
+	p.config = config{
+		idleTimeout:      snapshot.IdleTimeout,
+		queueTimeout:     snapshot.QueueTimeout,
+		dialTimeout:      snapshot.DialTimeout,
+		preferredVersion: snapshot.PreferredVersion,
+	}
+	return nil
+}
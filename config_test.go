@@ -0,0 +1,41 @@
+package grpcpool
+
+import (
+	"errors"
+	"testing"
+	"time"
+)
+
+func TestSnapshotRestoreConfig(t *testing.T) {
+	p, err := New(dialTestClient, 1, 2, time.Minute, WithQueueTimeout(time.Second))
+	if err != nil {
+		t.Errorf("The pool returned an error: %s", err.Error())
+	}
+	defer p.Close()
+
+	original := p.SnapshotConfig()
+	if original.Capacity != 2 || original.IdleTimeout != time.Minute ||
+		original.QueueTimeout != time.Second {
+		t.Errorf("The snapshot was %+v", original)
+	}
+
+	experiment := original
+	experiment.IdleTimeout = time.Hour
+	if err := p.RestoreConfig(experiment); err != nil {
+		t.Errorf("RestoreConfig returned an error: %s", err.Error())
+	}
+	if s := p.SnapshotConfig(); s.IdleTimeout != time.Hour {
+		t.Errorf("The idle timeout was %s but should be 1h", s.IdleTimeout)
+	}
+
+	experiment.Capacity = 3
+	if err := p.RestoreConfig(experiment); !errors.Is(err, ErrImmutableConfig) {
+		t.Errorf("Expected error \"%s\" but got \"%v\"", ErrImmutableConfig, err)
+	}
+	if err := p.RestoreConfig(original); err != nil {
+		t.Errorf("RestoreConfig returned an error: %s", err.Error())
+	}
+	if s := p.SnapshotConfig(); s != original {
+		t.Errorf("The restored config was %+v but should be %+v", s, original)
+	}
+}
@@ -0,0 +1,164 @@
+package grpcpool
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"sort"
+)
+
+var (
+	// ErrNotDrained is the error when DrainWhere returns before all the
+	// clients it drains were recycled
+	ErrNotDrained = errors.New("grpc pool: clients not drained")
+	// ErrDraining is the error when Get can't hand out a client because the
+	// pool is draining
+	ErrDraining = errors.New("grpc pool: client pool is draining")
+)
+
+// DrainMode tells how Get behaves while the pool is draining, see Drain
+type DrainMode int32
+
+const (
+	// DrainNone is the normal operation of the pool, not draining
+	DrainNone DrainMode = iota
+	// DrainReject makes Get fail right away with ErrDraining
+	DrainReject
+	// DrainServeWarm makes Get hand out the idle clients already dialed, to
+	// finish the work in progress, and fail with ErrDraining once there's
+	// none left instead of waiting for one to be returned
+	DrainServeWarm
+)
+
+// Drain starts draining the pool ahead of a shutdown, Get behaving according
+// to mode. In either mode, no new client is ever dialed while draining: Get
+// doesn't fill the placeholders nor replace the stale clients, handing them
+// out as they are with DrainServeWarm, WarmupAsync skips the placeholders
+// and ClientConn.MaybeRefresh keeps the current client. The Get calls
+// waiting for a client when the drain starts fail with ErrDraining. The
+// clients checked out are unaffected and can be returned as usual.
+// Drain(DrainNone) resumes the normal operation
+func (p *Pool) Drain(mode DrainMode) {
+	p.waitMu.Lock()
+	defer p.waitMu.Unlock()
+
+	p.drainMode.Store(int32(mode))
+	if mode != DrainNone {
+		// No client is idle if some Get calls are waiting, they fail like
+		// new ones would
+		p.wakeWaiters(ErrDraining)
+	}
+}
+
+// draining returns how the pool is draining, DrainNone if it isn't
+func (p *Pool) draining() DrainMode {
+	return DrainMode(p.drainMode.Load())
+}
+
+// takeWarm takes the first idle client matching pred out of the pool, without
+// waiting, for a Get while the pool serves warm clients only
+func (p *Pool) takeWarm(pred func(ConnInfo) bool) (ClientConn, error) {
+	wrapper, ok := p.takeWhere(pred)
+	if !ok {
+		return wrapper, ErrDraining
+	}
+	if wrapper.ClientConn == nil {
+		p.putBack(wrapper)
+		return wrapper, ErrDraining
+	}
+	return wrapper, nil
+}
+
+// DrainWhere recycles the clients for which pred returns true, e.g. the ones
+// connected to a backend about to be deployed, and returns once they're all
+// closed. The idle ones are recycled right away; the ones checked out are
+// recycled when returned to the pool, DrainWhere waiting for them until ctx
+// is done. It then returns an error wrapping ErrNotDrained listing the IDs of
+// the clients still checked out. Closing the pool ends the wait, all the
+// clients being closed anyway.
+//
+// The clients checked out are only known to the pool with
+// WithCheckoutTracking or WithStrictLifecycle: without either, DrainWhere
+// only recycles the idle clients
+func (p *Pool) DrainWhere(ctx context.Context, pred func(ConnInfo) bool) error {
+	if p.IsClosed() {
+		return ErrClosed
+	}
+
+	// The clients checked out are marked before sweeping the idle ones, so
+	// that a client returned meanwhile is either swept or recycled by put
+	pending := map[uint64]<-chan struct{}{}
+	if p.outstanding != nil {
+		p.outMu.Lock()
+		for conn, out := range p.outstanding {
+			if pred(out.meta.info(conn)) {
+				pending[out.meta.id] = out.meta.markDrain()
+			}
+		}
+		p.outMu.Unlock()
+	}
+	p.sweep(func(client ClientConn) ClientConn {
+		if client.ClientConn == nil || !pred(client.meta.info(client.ClientConn)) {
+			return client
+		}
+		p.recycle(client, ReasonDrained)
+		return ClientConn{pool: p}
+	})
+
+	for id, drained := range pending {
+		select {
+		case <-drained:
+			delete(pending, id)
+		case <-ctx.Done():
+		case <-p.ctx.Done():
+			return nil
+		}
+		if ctx.Err() != nil {
+			break
+		}
+	}
+	if len(pending) == 0 {
+		return nil
+	}
+	ids := make([]uint64, 0, len(pending))
+	for id := range pending {
+		ids = append(ids, id)
+	}
+	sort.Slice(ids, func(i, j int) bool { return ids[i] < ids[j] })
+	return fmt.Errorf("%w: %v", ErrNotDrained, ids)
+}
+
+// markDrain marks the client to be recycled once returned, returning the
+// channel closed when it is
+func (m *connMeta) markDrain() <-chan struct{} {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	if m.drained == nil {
+		m.drained = make(chan struct{})
+	}
+	return m.drained
+}
+
+// draining tells whether the client is marked to be recycled once returned
+func (m *connMeta) draining() bool {
+	if m == nil {
+		return false
+	}
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	return m.drained != nil
+}
+
+// notifyDrained tells DrainWhere the client was recycled, if it's waiting for
+// it
+func (m *connMeta) notifyDrained() {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	if m.drained != nil {
+		close(m.drained)
+		m.drained = nil
+	}
+}
@@ -0,0 +1,130 @@
+package grpcpool
+
+import (
+	"context"
+	"errors"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"google.golang.org/grpc"
+)
+
+func TestDrainWhere(t *testing.T) {
+	p, err := New(dialTestClient, 3, 3, 0, WithCheckoutTracking(false))
+	if err != nil {
+		t.Errorf("The pool returned an error: %s", err.Error())
+	}
+	defer p.Close()
+
+	held, err := p.Get(context.Background())
+	if err != nil {
+		t.Errorf("Get returned an error: %s", err.Error())
+	}
+	all := func(ConnInfo) bool { return true }
+	odd := func(info ConnInfo) bool { return info.ID%2 == 1 }
+
+	// The held client 1 isn't returned in time
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Millisecond)
+	defer cancel()
+	err = p.DrainWhere(ctx, odd)
+	if !errors.Is(err, ErrNotDrained) {
+		t.Errorf("Expected error \"%s\" but got \"%v\"", ErrNotDrained, err)
+	}
+	if s := p.Stats(); s.Drained != 1 {
+		t.Errorf("%d clients were drained but should be 1", s.Drained)
+	}
+
+	go func() {
+		time.Sleep(10 * time.Millisecond)
+		held.Close()
+	}()
+	if err := p.DrainWhere(context.Background(), all); err != nil {
+		t.Errorf("DrainWhere returned an error: %s", err.Error())
+	}
+	if s := p.Stats(); s.Drained != 3 || s.Available != 3 || s.Created-s.Closed != 0 {
+		t.Errorf("The stats were %+v", s)
+	}
+}
+
+func TestDrain(t *testing.T) {
+	p, err := New(dialTestClient, 1, 2, 0)
+	if err != nil {
+		t.Errorf("The pool returned an error: %s", err.Error())
+	}
+	defer p.Close()
+
+	p.Drain(DrainReject)
+	if _, err := p.Get(context.Background()); err != ErrDraining {
+		t.Errorf("Expected error \"%s\" but got \"%v\"", ErrDraining, err)
+	}
+
+	// The warm client is served, but the placeholder isn't dialed
+	p.Drain(DrainServeWarm)
+	client, err := p.Get(context.Background())
+	if err != nil {
+		t.Errorf("Get returned an error: %s", err.Error())
+	}
+	if _, err := p.Get(context.Background()); err != ErrDraining {
+		t.Errorf("Expected error \"%s\" but got \"%v\"", ErrDraining, err)
+	}
+	client.Close()
+	if s := p.Stats(); s.Created != 1 || s.Available != 2 {
+		t.Errorf("The stats were %+v", s)
+	}
+
+	p.Drain(DrainNone)
+	if _, err := p.GetWhere(context.Background(), func(ConnInfo) bool { return false }); err != nil {
+		t.Errorf("GetWhere returned an error: %s", err.Error())
+	}
+}
+
+func TestDrainWarmupAsync(t *testing.T) {
+	p, err := New(dialTestClient, 0, 2, 0)
+	if err != nil {
+		t.Errorf("The pool returned an error: %s", err.Error())
+	}
+	defer p.Close()
+
+	p.Drain(DrainReject)
+	done := p.WarmupAsync(context.Background(), func(*grpc.ClientConn) error { return nil })
+	if err := <-done; err != nil {
+		t.Errorf("WarmupAsync returned an error: %s", err.Error())
+	}
+	if s := p.Stats(); s.Created != 0 || s.Available != 2 {
+		t.Errorf("The stats were %+v", s)
+	}
+}
+
+func TestDrainWaiting(t *testing.T) {
+	var dials atomic.Int64
+	p, err := New(func() (*grpc.ClientConn, error) {
+		dials.Add(1)
+		return dialTestClient()
+	}, 1, 1, 0)
+	if err != nil {
+		t.Errorf("The pool returned an error: %s", err.Error())
+	}
+	defer p.Close()
+
+	client, err := p.Get(context.Background())
+	if err != nil {
+		t.Errorf("Get returned an error: %s", err.Error())
+	}
+	errs := make(chan error, 1)
+	go func() {
+		_, err := p.Get(context.Background())
+		errs <- err
+	}()
+	waitForWaiters(p, 1)
+
+	p.Drain(DrainReject)
+	client.Unhealthy()
+	client.Close()
+	if err := <-errs; err != ErrDraining {
+		t.Errorf("Expected error \"%s\" but got \"%v\"", ErrDraining, err)
+	}
+	if d := dials.Load(); d != 1 {
+		t.Errorf("The factory was called %d times but should be 1", d)
+	}
+}
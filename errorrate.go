@@ -0,0 +1,76 @@
+package grpcpool
+
+import (
+	"time"
+)
+
+// WithAutoEvictOnErrorRate recycles the clients whose error rate exceeds
+// threshold. Every window, a background loop computes for each idle client
+// the ratio of errors recorded with RecordError to checkouts since the
+// previous evaluation, closes the offenders and replaces them with
+// placeholders so that the next Get dials a fresh client. Checked out clients
+// are evaluated once they are returned
+func WithAutoEvictOnErrorRate(threshold float64, window time.Duration) Option {
+	return func(p *Pool) {
+		p.autoEvictThreshold = threshold
+		p.autoEvictWindow = window
+	}
+}
+
+// RecordError records that an RPC failed on the client, for the error rate
+// based eviction. The AsClientConn adapter records the errors of its RPCs
+// itself. Recording a nil error does nothing
+func (c *ClientConn) RecordError(err error) {
+	if err == nil || c == nil || c.meta == nil {
+		return
+	}
+	c.meta.mu.Lock()
+	c.meta.errors++
+	c.meta.lastErrorAt = time.Now()
+	c.meta.mu.Unlock()
+	c.meta.record(EventError, ReasonNone, err)
+}
+
+// NoteSuccess clears the errors recorded for the client with RecordError and
+// the time of the last one, once the caller knows the client recovered, e.g.
+// after a successful RPC following a series of failures, so that past errors
+// don't get it recycled for its error rate. The AsClientConn adapter doesn't
+// call it on every successful RPC, which would void the error rate
+func (c *ClientConn) NoteSuccess() {
+	if c == nil || c.meta == nil {
+		return
+	}
+	c.meta.mu.Lock()
+	c.meta.errors = 0
+	c.meta.lastErrorAt = time.Time{}
+	c.meta.mu.Unlock()
+}
+
+// AutoEvicted returns the number of clients recycled for exceeding the error
+// rate threshold
+func (p *Pool) AutoEvicted() int64 {
+	return p.counters.autoEvicted.Load()
+}
+
+// evictOnErrorRate evaluates the error rate of the idle clients, recycling
+// the ones over the threshold and resetting the counts of the others
+func (p *Pool) evictOnErrorRate() {
+	p.sweep(func(client ClientConn) ClientConn {
+		if client.ClientConn == nil {
+			return client
+		}
+
+		client.meta.mu.Lock()
+		uses, errors := client.meta.uses, client.meta.errors
+		client.meta.uses, client.meta.errors = 0, 0
+		client.meta.mu.Unlock()
+
+		if uses == 0 || float64(errors)/float64(uses) <= p.autoEvictThreshold {
+			return client
+		}
+		p.recycle(client, ReasonErrorRate)
+		return ClientConn{
+			pool: p,
+		}
+	})
+}
@@ -0,0 +1,84 @@
+package grpcpool
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+)
+
+func TestAutoEvictOnErrorRate(t *testing.T) {
+	p, err := New(dialTestClient, 1, 1, 0, WithAutoEvictOnErrorRate(0.5, 10*time.Millisecond))
+	if err != nil {
+		t.Errorf("The pool returned an error: %s", err.Error())
+	}
+
+	client, err := p.Get(context.Background())
+	if err != nil {
+		t.Errorf("Get returned an error: %s", err.Error())
+	}
+	client.RecordError(errors.New("rpc failed"))
+	client.Close()
+
+	deadline := time.Now().Add(time.Second)
+	for p.AutoEvicted() == 0 && time.Now().Before(deadline) {
+		time.Sleep(5 * time.Millisecond)
+	}
+	if e := p.AutoEvicted(); e != 1 {
+		t.Errorf("The pool auto evicted %d clients but should have evicted 1", e)
+	}
+	if a := p.Available(); a != 1 {
+		t.Errorf("The pool available was %d but should be 1", a)
+	}
+}
+
+func TestCloseKeepWarm(t *testing.T) {
+	p, err := New(dialTestClient, 1, 1, 0,
+		WithAutoEvictOnErrorRate(0.5, 10*time.Millisecond))
+	if err != nil {
+		t.Errorf("The pool returned an error: %s", err.Error())
+	}
+	defer p.Close()
+
+	client, err := p.Get(context.Background())
+	if err != nil {
+		t.Errorf("Get returned an error: %s", err.Error())
+	}
+	client.RecordError(errors.New("rpc failed"))
+	if err := client.CloseKeepWarm(); err != nil {
+		t.Errorf("CloseKeepWarm returned an error: %s", err.Error())
+	}
+
+	time.Sleep(50 * time.Millisecond)
+	if e := p.AutoEvicted(); e != 0 {
+		t.Errorf("The pool auto evicted %d clients but should have evicted 0", e)
+	}
+}
+
+func TestNoteSuccess(t *testing.T) {
+	p, err := New(dialTestClient, 1, 1, 0,
+		WithAutoEvictOnErrorRate(0.5, 10*time.Millisecond), WithCheckoutTracking(false))
+	if err != nil {
+		t.Errorf("The pool returned an error: %s", err.Error())
+	}
+	defer p.Close()
+
+	client, err := p.Get(context.Background())
+	if err != nil {
+		t.Errorf("Get returned an error: %s", err.Error())
+	}
+	client.RecordError(errors.New("rpc failed"))
+	if out := p.CheckedOut(); out[0].LastErrorAt.IsZero() {
+		t.Errorf("The time of the last error should have been recorded")
+	}
+	client.NoteSuccess()
+	if out := p.CheckedOut(); !out[0].LastErrorAt.IsZero() {
+		t.Errorf("The time of the last error should have been cleared")
+	}
+	client.Close()
+
+	time.Sleep(50 * time.Millisecond)
+	if e := p.AutoEvicted(); e != 0 {
+		t.Errorf("The pool auto evicted %d clients but should have evicted 0", e)
+	}
+}
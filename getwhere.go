@@ -0,0 +1,106 @@
+package grpcpool
+
+import (
+	"context"
+)
+
+// GetWhere is like Get, but hands out an idle client for which pred returns
+// true, leaving the other idle clients in the pool untouched and in order. If
+// none matches, it takes the next client like Get: a placeholder is dialed,
+// while a warm client that doesn't match is recycled with ReasonNoMatch to
+// dial a new one in its slot. The client dialed isn't guaranteed to match
+// pred, which is up to the factory: with a pred no client the factory creates
+// matches, every GetWhere replaces a client, churning through the whole pool.
+// With WithFixedConns, as nothing could replace it, the client taken is
+// handed out as it is, matching pred or not.
+//
+// Finding a match scans all the idle clients, calling pred on each one while
+// holding the pool read lock: on a large pool under contention, it's much
+// more expensive than Get, and pred should be cheap
+func (p *Pool) GetWhere(ctx context.Context, pred func(ConnInfo) bool) (*ClientConn, error) {
+	clients, err := p.admit(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	cfg := p.currentConfig()
+	if p.draining() == DrainServeWarm {
+		wrapper, err := p.takeWarm(pred)
+		if err != nil {
+			return nil, err
+		}
+		p.notePeak(clients)
+		return p.checkOutFrom(ctx, wrapper, cfg, false)
+	}
+	wrapper, ok := p.takeWhere(pred)
+	if ok && wrapper.ClientConn == nil && p.holdBack() {
+		ok = false
+	}
+	if !ok {
+		if wrapper, err = p.acquire(ctx, clients, cfg, 0); err != nil {
+			return nil, err
+		}
+		if wrapper.ClientConn != nil && p.fixedConns == nil &&
+			!pred(wrapper.meta.info(wrapper.ClientConn)) {
+			p.recycle(wrapper, ReasonNoMatch)
+			wrapper = ClientConn{pool: p}
+		}
+	}
+	p.notePeak(clients)
+	return p.checkOutFrom(ctx, wrapper, cfg, true)
+}
+
+// takeWhere takes the first idle client matching pred out of the pool,
+// cycling the other clients back in place. Failing a match, it takes a
+// placeholder if there's one
+func (p *Pool) takeWhere(pred func(ConnInfo) bool) (ClientConn, bool) {
+	p.mu.RLock()
+	defer p.mu.RUnlock()
+
+	if p.clients == nil {
+		return ClientConn{}, false
+	}
+	var (
+		taken       ClientConn
+		found       bool
+		placeholder bool
+	)
+	for i := len(p.clients); i > 0; i-- {
+		var client ClientConn
+		select {
+		case client = <-p.clients:
+		default:
+		}
+		if client.pool == nil {
+			break
+		}
+		switch {
+		case found:
+		case client.ClientConn == nil:
+			if !placeholder {
+				// Kept aside in case no client matches
+				placeholder = true
+				continue
+			}
+		case pred(client.meta.info(client.ClientConn)):
+			taken, found = client, true
+			continue
+		}
+		if !p.send(client) && client.ClientConn != nil {
+			// Can't happen as we just took a slot, but never leak a client
+			p.closeConn(client.ClientConn)
+		}
+	}
+
+	if found {
+		if placeholder {
+			p.send(ClientConn{pool: p})
+		}
+		p.inTransit.Add(1)
+		return taken, true
+	}
+	if placeholder {
+		p.inTransit.Add(1)
+	}
+	return ClientConn{pool: p}, placeholder
+}
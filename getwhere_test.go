@@ -0,0 +1,59 @@
+package grpcpool
+
+import (
+	"context"
+	"fmt"
+	"testing"
+
+	"google.golang.org/grpc"
+)
+
+func TestGetWhere(t *testing.T) {
+	var n int
+	p, err := New(nil, 3, 3, 0, WithLabeledFactory(
+		func() (*grpc.ClientConn, map[string]string, error) {
+			n++
+			conn, err := dialTestClient()
+			return conn, map[string]string{"zone": fmt.Sprint(n)}, err
+		}))
+	if err != nil {
+		t.Errorf("The pool returned an error: %s", err.Error())
+	}
+	defer p.Close()
+
+	client, err := p.GetWhere(context.Background(), func(info ConnInfo) bool {
+		return info.Labels["zone"] == "2"
+	})
+	if err != nil {
+		t.Errorf("GetWhere returned an error: %s", err.Error())
+	}
+	if zone := client.Labels()["zone"]; zone != "2" {
+		t.Errorf("GetWhere returned a client of zone %q but should be 2", zone)
+	}
+	client.Close()
+
+	// The other clients were left in order
+	client, err = p.Get(context.Background())
+	if err != nil {
+		t.Errorf("Get returned an error: %s", err.Error())
+	}
+	if zone := client.Labels()["zone"]; zone != "1" {
+		t.Errorf("Get returned a client of zone %q but should be 1", zone)
+	}
+	client.Close()
+
+	// Failing a match, a client is closed to dial a new one
+	client, err = p.GetWhere(context.Background(), func(info ConnInfo) bool {
+		return info.Labels["zone"] == "4"
+	})
+	if err != nil {
+		t.Errorf("GetWhere returned an error: %s", err.Error())
+	}
+	if zone := client.Labels()["zone"]; zone != "4" {
+		t.Errorf("GetWhere returned a client of zone %q but should be 4", zone)
+	}
+	client.Close()
+	if s := p.Stats(); s.Created != 4 || s.Closed != 1 || s.NoMatch != 1 {
+		t.Errorf("The stats were %+v", s)
+	}
+}
@@ -0,0 +1,61 @@
+package grpcpool
+
+// WithGrowthLimit sets a soft ceiling of n clients under the capacity given
+// to New, which becomes the burst ceiling: only n slots circulate in the pool
+// normally, the others being held back until AllowBurst releases them. The
+// initial clients are capped to n too. Capacity still returns the burst
+// ceiling, while Stats counts the slots held back neither available nor in
+// use
+func WithGrowthLimit(n int) Option {
+	return func(p *Pool) {
+		p.growthLimit = n
+	}
+}
+
+// AllowBurst lets the pool grow beyond the growth limit set with
+// WithGrowthLimit, up to its capacity, or brings it back under the limit. The
+// slots held back are released right away as placeholders, dialed by Get on
+// demand. Once bursting is over, slots are held back again as Get comes
+// across placeholders, so that the warm clients are kept until recycled
+func (p *Pool) AllowBurst(allow bool) {
+	p.burst.Store(allow)
+	if !allow {
+		return
+	}
+
+	p.mu.RLock()
+	defer p.mu.RUnlock()
+
+	if p.clients == nil {
+		return
+	}
+	for {
+		n := p.reserved.Load()
+		if n <= 0 {
+			return
+		}
+		if p.reserved.CompareAndSwap(n, n-1) {
+			p.send(ClientConn{pool: p})
+		}
+	}
+}
+
+// holdBack holds back the slot of a placeholder taken out of the pool if the
+// pool is beyond its growth limit, returning false if the slot should be
+// used instead
+func (p *Pool) holdBack() bool {
+	if p.growthLimit <= 0 || p.burst.Load() {
+		return false
+	}
+	limit := int64(cap(p.getClients()) - p.growthLimit)
+	for {
+		n := p.reserved.Load()
+		if n >= limit {
+			return false
+		}
+		if p.reserved.CompareAndSwap(n, n+1) {
+			p.inTransit.Add(-1)
+			return true
+		}
+	}
+}
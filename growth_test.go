@@ -0,0 +1,51 @@
+package grpcpool
+
+import (
+	"context"
+	"testing"
+	"time"
+)
+
+func TestWithGrowthLimit(t *testing.T) {
+	p, err := New(dialTestClient, 3, 3, 0, WithGrowthLimit(1))
+	if err != nil {
+		t.Errorf("The pool returned an error: %s", err.Error())
+	}
+	defer p.Close()
+	if s := p.Stats(); s.Capacity != 3 || s.Available != 1 || s.InUse != 0 || s.Created != 1 {
+		t.Errorf("The stats were %+v", s)
+	}
+
+	client, err := p.Get(context.Background())
+	if err != nil {
+		t.Errorf("Get returned an error: %s", err.Error())
+	}
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Millisecond)
+	defer cancel()
+	if _, err := p.Get(ctx); err != ErrTimeout {
+		t.Errorf("Expected error \"%s\" but got \"%v\"", ErrTimeout, err)
+	}
+
+	p.AllowBurst(true)
+	client2, err := p.Get(context.Background())
+	if err != nil {
+		t.Errorf("Get returned an error: %s", err.Error())
+	}
+	if s := p.Stats(); s.Available != 1 || s.InUse != 2 {
+		t.Errorf("The stats were %+v", s)
+	}
+
+	// Back under the limit, the placeholders are held back again
+	p.AllowBurst(false)
+	client2.Unhealthy()
+	client2.Close()
+	client.Close()
+	client, err = p.Get(context.Background())
+	if err != nil {
+		t.Errorf("Get returned an error: %s", err.Error())
+	}
+	if s := p.Stats(); s.Available != 0 || s.InUse != 1 {
+		t.Errorf("The stats were %+v", s)
+	}
+	client.Close()
+}
@@ -0,0 +1,33 @@
+// Package grpcpoolexpvar publishes the state of a grpc pool through expvar,
+// making it visible on /debug/vars without any metrics dependency
+package grpcpoolexpvar
+
+import (
+	"errors"
+	"expvar"
+	"sync"
+
+	grpcpool "github.com/processout/grpc-go-pool"
+)
+
+// ErrAlreadyPublished is the error when a variable is already published
+// under the requested name
+var ErrAlreadyPublished = errors.New("grpc pool: expvar name already published")
+
+var mu sync.Mutex
+
+// Publish registers an expvar.Func under name exposing the pool Stats as
+// JSON. Publishing under a name already in use returns ErrAlreadyPublished
+// instead of panicking like expvar.Publish does
+func Publish(name string, p *grpcpool.Pool) error {
+	mu.Lock()
+	defer mu.Unlock()
+
+	if expvar.Get(name) != nil {
+		return ErrAlreadyPublished
+	}
+	expvar.Publish(name, expvar.Func(func() any {
+		return p.Stats()
+	}))
+	return nil
+}
@@ -0,0 +1,41 @@
+package grpcpoolexpvar
+
+import (
+	"encoding/json"
+	"expvar"
+	"fmt"
+	"sync/atomic"
+	"testing"
+
+	grpcpool "github.com/processout/grpc-go-pool"
+	"google.golang.org/grpc"
+)
+
+// runs numbers the runs of a test, expvar names being global to the process
+// even across the repeats of go test -count
+var runs atomic.Int64
+
+func TestPublish(t *testing.T) {
+	name := fmt.Sprintf("%s_%d", t.Name(), runs.Add(1))
+	p, err := grpcpool.New(func() (*grpc.ClientConn, error) {
+		return &grpc.ClientConn{}, nil
+	}, 1, 2, 0)
+	if err != nil {
+		t.Errorf("The pool returned an error: %s", err.Error())
+	}
+
+	if err := Publish(name, p); err != nil {
+		t.Errorf("Publish returned an error: %s", err.Error())
+	}
+	if err := Publish(name, p); err != ErrAlreadyPublished {
+		t.Errorf("Expected error \"%s\" but got \"%v\"", ErrAlreadyPublished, err)
+	}
+
+	var stats grpcpool.Stats
+	if err := json.Unmarshal([]byte(expvar.Get(name).String()), &stats); err != nil {
+		t.Errorf("The published value was not valid JSON: %s", err.Error())
+	}
+	if stats.Capacity != 2 || stats.Created != 1 {
+		t.Errorf("The published stats were %+v", stats)
+	}
+}
@@ -0,0 +1,90 @@
+// Package grpcpoolotel exports the state of a grpc pool as OpenTelemetry
+// metrics, keeping the OpenTelemetry dependency out of the pool package
+package grpcpoolotel
+
+import (
+	"context"
+
+	grpcpool "github.com/processout/grpc-go-pool"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/metric"
+)
+
+// RegisterMetrics registers observable instruments on meter reporting the
+// pool Stats, labeled with the pool target:
+//
+//   - grpc.pool.capacity, grpc.pool.available, grpc.pool.in_use and
+//     grpc.pool.waiting gauges
+//   - grpc.pool.created, grpc.pool.closed, grpc.pool.evicted and
+//     grpc.pool.dials counters
+//   - a grpc.pool.dial.duration counter of the total time spent dialing, in
+//     seconds. OpenTelemetry has no asynchronous histogram, the mean dial
+//     latency is its rate divided by the one of grpc.pool.dials
+//
+// The stats are read once per collection, which is safe concurrently with
+// the pool operations. Unregister the returned registration to stop
+// reporting, typically once the pool is closed
+func RegisterMetrics(p *grpcpool.Pool, meter metric.Meter) (metric.Registration, error) {
+	capacity, err := meter.Int64ObservableGauge("grpc.pool.capacity",
+		metric.WithDescription("Maximum number of clients of the pool"))
+	if err != nil {
+		return nil, err
+	}
+	available, err := meter.Int64ObservableGauge("grpc.pool.available",
+		metric.WithDescription("Number of clients, warm or not, waiting in the pool"))
+	if err != nil {
+		return nil, err
+	}
+	inUse, err := meter.Int64ObservableGauge("grpc.pool.in_use",
+		metric.WithDescription("Number of clients checked out of the pool"))
+	if err != nil {
+		return nil, err
+	}
+	waiting, err := meter.Int64ObservableGauge("grpc.pool.waiting",
+		metric.WithDescription("Number of Get calls waiting for a client"))
+	if err != nil {
+		return nil, err
+	}
+	created, err := meter.Int64ObservableCounter("grpc.pool.created",
+		metric.WithDescription("Number of clients created by the factory"))
+	if err != nil {
+		return nil, err
+	}
+	closed, err := meter.Int64ObservableCounter("grpc.pool.closed",
+		metric.WithDescription("Number of clients closed by the pool"))
+	if err != nil {
+		return nil, err
+	}
+	evicted, err := meter.Int64ObservableCounter("grpc.pool.evicted",
+		metric.WithDescription("Number of clients recycled for being idle too long"))
+	if err != nil {
+		return nil, err
+	}
+	dials, err := meter.Int64ObservableCounter("grpc.pool.dials",
+		metric.WithDescription("Number of factory calls, successful or not"))
+	if err != nil {
+		return nil, err
+	}
+	dialDuration, err := meter.Float64ObservableCounter("grpc.pool.dial.duration",
+		metric.WithDescription("Total time spent in the factory"),
+		metric.WithUnit("s"))
+	if err != nil {
+		return nil, err
+	}
+
+	return meter.RegisterCallback(func(_ context.Context, o metric.Observer) error {
+		s := p.Stats()
+		attrs := metric.WithAttributes(attribute.String("grpc.pool.target", p.Target()))
+		o.ObserveInt64(capacity, int64(s.Capacity), attrs)
+		o.ObserveInt64(available, int64(s.Available), attrs)
+		o.ObserveInt64(inUse, int64(s.InUse), attrs)
+		o.ObserveInt64(waiting, int64(s.Waiting), attrs)
+		o.ObserveInt64(created, s.Created, attrs)
+		o.ObserveInt64(closed, s.Closed, attrs)
+		o.ObserveInt64(evicted, s.Evicted, attrs)
+		o.ObserveInt64(dials, int64(p.FactoryCalls()), attrs)
+		o.ObserveFloat64(dialDuration, s.DialDuration.Seconds(), attrs)
+		return nil
+	}, capacity, available, inUse, waiting, created, closed, evicted, dials,
+		dialDuration)
+}
@@ -0,0 +1,48 @@
+package grpcpoolotel
+
+import (
+	"context"
+	"testing"
+
+	grpcpool "github.com/processout/grpc-go-pool"
+	sdkmetric "go.opentelemetry.io/otel/sdk/metric"
+	"go.opentelemetry.io/otel/sdk/metric/metricdata"
+	"google.golang.org/grpc"
+)
+
+func TestRegisterMetrics(t *testing.T) {
+	p, err := grpcpool.New(func() (*grpc.ClientConn, error) {
+		return &grpc.ClientConn{}, nil
+	}, 1, 2, 0)
+	if err != nil {
+		t.Errorf("The pool returned an error: %s", err.Error())
+	}
+
+	reader := sdkmetric.NewManualReader()
+	provider := sdkmetric.NewMeterProvider(sdkmetric.WithReader(reader))
+	reg, err := RegisterMetrics(p, provider.Meter("grpcpool"))
+	if err != nil {
+		t.Errorf("RegisterMetrics returned an error: %s", err.Error())
+	}
+	defer reg.Unregister()
+
+	var rm metricdata.ResourceMetrics
+	if err := reader.Collect(context.Background(), &rm); err != nil {
+		t.Errorf("Collect returned an error: %s", err.Error())
+	}
+	values := map[string]int64{}
+	for _, sm := range rm.ScopeMetrics {
+		for _, m := range sm.Metrics {
+			switch data := m.Data.(type) {
+			case metricdata.Gauge[int64]:
+				values[m.Name] = data.DataPoints[0].Value
+			case metricdata.Sum[int64]:
+				values[m.Name] = data.DataPoints[0].Value
+			}
+		}
+	}
+	if values["grpc.pool.capacity"] != 2 || values["grpc.pool.available"] != 2 ||
+		values["grpc.pool.created"] != 1 || values["grpc.pool.dials"] != 1 {
+		t.Errorf("The metrics were %v", values)
+	}
+}
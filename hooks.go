@@ -0,0 +1,123 @@
+package grpcpool
+
+import (
+	"time"
+)
+
+// CloseReason tells why the pool closed a client
+type CloseReason int
+
+const (
+	// ReasonNone means the client wasn't closed
+	ReasonNone CloseReason = iota
+	// ReasonUnhealthy means the client was marked unhealthy
+	ReasonUnhealthy
+	// ReasonPoolClosed means the client was returned to a closed pool
+	ReasonPoolClosed
+	// ReasonIdleTimeout means the client was idle for longer than the idle
+	// timeout
+	ReasonIdleTimeout
+	// ReasonOutdatedVersion means the client was noted with another backend
+	// version than the preferred one
+	ReasonOutdatedVersion
+	// ReasonErrorRate means the client error rate exceeded the threshold
+	ReasonErrorRate
+	// ReasonStaleAddress means the client address wasn't among the ones the
+	// target resolves to anymore
+	ReasonStaleAddress
+	// ReasonOversized means the estimated size of the client exceeded the
+	// maximum set with WithMaxConnBytes
+	ReasonOversized
+	// ReasonRefresh means the client was created before the last
+	// Pool.RefreshAll
+	ReasonRefresh
+	// ReasonDrained means the client was drained by Pool.DrainWhere
+	ReasonDrained
+	// ReasonNoMatch means no idle client matched the predicate of
+	// Pool.GetWhere, which closed the one it took to dial a new one
+	ReasonNoMatch
+)
+
+// String returns a short name for the reason, fit for a metric label
+func (r CloseReason) String() string {
+	switch r {
+	case ReasonNone:
+		return "none"
+	case ReasonUnhealthy:
+		return "unhealthy"
+	case ReasonPoolClosed:
+		return "pool_closed"
+	case ReasonIdleTimeout:
+		return "idle_timeout"
+	case ReasonOutdatedVersion:
+		return "outdated_version"
+	case ReasonErrorRate:
+		return "error_rate"
+	case ReasonStaleAddress:
+		return "stale_address"
+	case ReasonOversized:
+		return "oversized"
+	case ReasonRefresh:
+		return "refresh"
+	case ReasonDrained:
+		return "drained"
+	case ReasonNoMatch:
+		return "no_match"
+	default:
+		return "unknown"
+	}
+}
+
+// WithOnReturn registers a hook called each time a client is returned to the
+// pool with ClientConn.Close, telling whether the client was recycled instead
+// of pooled, and why. It isn't called when the return fails with
+// ErrFullPool, the client being left untouched. The hook runs outside of the
+// pool locks, in the goroutine returning the client
+func WithOnReturn(fn func(recycled bool, reason CloseReason)) Option {
+	return func(p *Pool) {
+		p.onReturn = fn
+	}
+}
+
+// returned reports the outcome of a return to the OnReturn hook
+func (p *Pool) returned(reason CloseReason, err error) {
+	if p.onReturn == nil || err == ErrFullPool {
+		return
+	}
+	p.onReturn(reason != ReasonNone, reason)
+}
+
+// WithOnCloseError registers a hook called with every error returned by
+// ClientConn.Close (ErrFullPool, ErrClosed, ErrAlreadyClosed...), giving a
+// central place to observe them for callers which don't check the error of
+// every Close. Close still returns the error. The hook runs outside of the
+// pool locks, in the goroutine returning the client
+func WithOnCloseError(fn func(err error)) Option {
+	return func(p *Pool) {
+		p.onCloseError = fn
+	}
+}
+
+// WithOnLongIdleReuse registers a hook called by Get when it hands out a
+// client which had been idle in the pool for longer than threshold, but not
+// long enough to be recycled by the idle timeout, to correlate latency spikes
+// with the reuse of cold connections. The hook is given the client and how
+// long it was idle, and runs in the goroutine calling Get, before it returns
+func WithOnLongIdleReuse(threshold time.Duration,
+	fn func(info ConnInfo, idleFor time.Duration)) Option {
+	return func(p *Pool) {
+		p.longIdleThreshold = threshold
+		p.onLongIdleReuse = fn
+	}
+}
+
+// noteIdleReuse reports a client reused after a long idle time to the
+// LongIdleReuse hook
+func (p *Pool) noteIdleReuse(client ClientConn) {
+	if p.onLongIdleReuse == nil {
+		return
+	}
+	if idle := time.Since(client.timeUsed); idle > p.longIdleThreshold {
+		p.onLongIdleReuse(client.meta.info(client.ClientConn), idle)
+	}
+}
@@ -0,0 +1,97 @@
+package grpcpool
+
+import (
+	"context"
+	"testing"
+	"time"
+)
+
+func TestWithOnReturn(t *testing.T) {
+	var reasons []CloseReason
+	p, err := New(dialTestClient, 1, 2, 0,
+		WithOnReturn(func(recycled bool, reason CloseReason) {
+			if recycled != (reason != ReasonNone) {
+				t.Errorf("Recycled was %t for reason %s", recycled, reason)
+			}
+			reasons = append(reasons, reason)
+		}))
+	if err != nil {
+		t.Errorf("The pool returned an error: %s", err.Error())
+	}
+
+	cl1, _ := p.Get(context.Background())
+	cl2, _ := p.Get(context.Background())
+	cl1.Close()
+	cl2.Unhealthy()
+	cl2.Close()
+	cl3, _ := p.Get(context.Background())
+	p.Close()
+	cl3.Close()
+
+	expected := []CloseReason{ReasonNone, ReasonUnhealthy, ReasonPoolClosed}
+	if len(reasons) != len(expected) {
+		t.Fatalf("The reasons were %v but should be %v", reasons, expected)
+	}
+	for i := range expected {
+		if reasons[i] != expected[i] {
+			t.Errorf("The reasons were %v but should be %v", reasons, expected)
+		}
+	}
+}
+
+func TestWithOnCloseError(t *testing.T) {
+	var errs []error
+	p, err := New(dialTestClient, 1, 1, 0,
+		WithOnCloseError(func(err error) { errs = append(errs, err) }))
+	if err != nil {
+		t.Errorf("The pool returned an error: %s", err.Error())
+	}
+
+	client, _ := p.Get(context.Background())
+	if err := client.Close(); err != nil {
+		t.Errorf("Close returned an error: %s", err.Error())
+	}
+	if err := client.Close(); err != ErrAlreadyClosed {
+		t.Errorf("Expected error \"%s\" but got \"%v\"", ErrAlreadyClosed, err)
+	}
+	client, _ = p.Get(context.Background())
+	p.Close()
+	if err := client.Close(); err != ErrClosed {
+		t.Errorf("Expected error \"%s\" but got \"%v\"", ErrClosed, err)
+	}
+
+	if len(errs) != 2 || errs[0] != ErrAlreadyClosed || errs[1] != ErrClosed {
+		t.Errorf("The hook got %v", errs)
+	}
+}
+
+func TestWithOnLongIdleReuse(t *testing.T) {
+	var idles []time.Duration
+	p, err := New(dialTestClient, 1, 1, time.Hour,
+		WithOnLongIdleReuse(20*time.Millisecond, func(info ConnInfo, idleFor time.Duration) {
+			if info.ID != 1 {
+				t.Errorf("The hook was called for client %d but should be 1", info.ID)
+			}
+			idles = append(idles, idleFor)
+		}))
+	if err != nil {
+		t.Errorf("The pool returned an error: %s", err.Error())
+	}
+	defer p.Close()
+
+	client, err := p.Get(context.Background())
+	if err != nil {
+		t.Errorf("Get returned an error: %s", err.Error())
+	}
+	client.Close()
+	time.Sleep(30 * time.Millisecond)
+	client, err = p.Get(context.Background())
+	if err != nil {
+		t.Errorf("Get returned an error: %s", err.Error())
+	}
+	client.Close()
+
+	if len(idles) != 1 || idles[0] < 20*time.Millisecond {
+		t.Errorf("The hook was called with %v", idles)
+	}
+}
@@ -0,0 +1,149 @@
+package grpcpool
+
+import (
+	"sync"
+	"time"
+
+	"google.golang.org/grpc"
+)
+
+// Option configures optional behaviour of a Pool. Options are applied by New
+// before the initial clients are created
+type Option func(*Pool)
+
+// WithStrictLifecycle enables assertions on the lifecycle of the clients
+// handed out by the pool: closing a ClientConn twice (including through a
+// copy of the wrapper), or closing a ClientConn that wasn't checked out from
+// the pool it's returned to, panics instead of failing silently.
+//
+// It is meant as a development aid, typically enabled in tests, as tracking
+// the checked out clients adds a lock and a map lookup to Get and Close
+func WithStrictLifecycle() Option {
+	return func(p *Pool) {
+		p.strict = true
+		p.trackCheckouts()
+	}
+}
+
+// WithCompressor makes the RPCs performed through the AsClientConn adapter
+// compress their messages with the named compressor, by adding a
+// grpc.UseCompressor default call option. The compressor must be registered
+// beforehand, typically by importing its package for side effects (e.g.
+// google.golang.org/grpc/encoding/gzip), and the server must support it.
+// Connections used directly through Get are not affected
+func WithCompressor(name string) Option {
+	return func(p *Pool) {
+		p.defaultCallOptions = append(p.defaultCallOptions,
+			grpc.UseCompressor(name))
+	}
+}
+
+// WithDefaultRPCTimeout bounds the RPCs performed through the AsClientConn
+// adapter whose context has no deadline to d, checkout included. For a
+// stream, d bounds the whole stream. A deadline already set on the context is
+// never overridden, even if it's further than d
+func WithDefaultRPCTimeout(d time.Duration) Option {
+	return func(p *Pool) {
+		p.defaultRPCTimeout = d
+	}
+}
+
+// WithTarget sets the target reported by Pool.Target, which otherwise is the
+// target of the first client created by the factory
+func WithTarget(target string) Option {
+	return func(p *Pool) {
+		p.target.Store(&target)
+	}
+}
+
+// WithExpectedTarget makes the pool check that every client created by the
+// factory was dialed to target, as reported by grpc.ClientConn.Target.
+// Mismatching clients are closed right away and the dial fails with an error
+// wrapping ErrTargetMismatch. The comparison is exact, so target must be
+// spelled the way the factory dials it (scheme included): it's meant to catch
+// a misconfigured factory, not to follow a load-balanced or resolved target
+func WithExpectedTarget(target string) Option {
+	return func(p *Pool) {
+		p.expectedTarget = target
+	}
+}
+
+// WithPreferredVersion makes Get recycle the idle clients noted, with
+// ClientConn.NoteVersion, as connected to another backend version than v, so
+// that the pool moves to the new version as it rolls out. Clients with no
+// noted version are left alone
+func WithPreferredVersion(v string) Option {
+	return func(p *Pool) {
+		p.config.preferredVersion = v
+	}
+}
+
+// WithSalvageCanceledDials makes Get return ErrTimeout as soon as its context
+// is done, even while the factory is dialing a new client. The dial carries on
+// in the background and, if it succeeds, its client is pooled for the next
+// caller rather than wasted. It's closed instead if the pool was closed
+// meanwhile
+func WithSalvageCanceledDials() Option {
+	return func(p *Pool) {
+		p.salvageDials = true
+	}
+}
+
+// WithQueueTimeout bounds how long Get waits for a client to be available in
+// the pool, returning ErrQueueTimeout past it. The context given to Get still
+// bounds the whole call
+func WithQueueTimeout(d time.Duration) Option {
+	return func(p *Pool) {
+		p.config.queueTimeout = d
+	}
+}
+
+// WithDialTimeout bounds how long Get waits for the factory to create a new
+// client, returning ErrDialTimeout past it. As the factory can't be
+// interrupted, the dial carries on in the background and its client is
+// closed when it completes, unless WithSalvageCanceledDials is set too
+func WithDialTimeout(d time.Duration) Option {
+	return func(p *Pool) {
+		p.config.dialTimeout = d
+	}
+}
+
+// WithLabeledFactory makes the pool create its clients with factory instead of
+// the Factory given to New, which may then be nil. The labels returned along
+// with each client are available through ClientConn.Labels
+func WithLabeledFactory(factory LabeledFactory) Option {
+	return func(p *Pool) {
+		p.labeledFactory = factory
+	}
+}
+
+// WithFixedConns makes the pool a pure checkout queue over conns: its
+// capacity and initial clients are the connections seeded, whatever the ones
+// given to New, and it never dials, the factory passed to New being ignored.
+// Get waits when all the connections are checked out, and a connection
+// recycled (e.g. after ClientConn.Unhealthy) isn't replaced, a Get in its
+// slot returning ErrFixedConns. As nothing could replace them, the seeded
+// connections are never recycled for being stale: the idle timeout, RefreshAll,
+// WithPreferredVersion and WithMaxConnBytes don't apply. Close closes all the
+// seeded connections
+func WithFixedConns(conns []*grpc.ClientConn) Option {
+	return func(p *Pool) {
+		p.fixedConns = conns
+		p.labeledFactory = nil
+
+		var (
+			mu   sync.Mutex
+			next int
+		)
+		p.factory = func() (*grpc.ClientConn, error) {
+			mu.Lock()
+			defer mu.Unlock()
+
+			if next == len(conns) {
+				return nil, ErrFixedConns
+			}
+			next++
+			return conns[next-1], nil
+		}
+	}
+}
@@ -0,0 +1,283 @@
+package grpcpool
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/connectivity"
+)
+
+func expectPanic(t *testing.T, name string, fn func()) {
+	t.Helper()
+	defer func() {
+		if recover() == nil {
+			t.Errorf("%s should have panicked", name)
+		}
+	}()
+	fn()
+}
+
+func TestStrictLifecycle(t *testing.T) {
+	p, err := New(func() (*grpc.ClientConn, error) {
+		return &grpc.ClientConn{}, nil
+	}, 1, 1, 0, WithStrictLifecycle())
+	if err != nil {
+		t.Errorf("The pool returned an error: %s", err.Error())
+	}
+
+	client, err := p.Get(context.Background())
+	if err != nil {
+		t.Errorf("Get returned an error: %s", err.Error())
+	}
+	copied := *client
+	if err := client.Close(); err != nil {
+		t.Errorf("Close returned an error: %s", err.Error())
+	}
+	expectPanic(t, "Double Close", func() { client.Close() })
+	expectPanic(t, "Close of a copy", func() { copied.Close() })
+	if a := p.Available(); a != 1 {
+		t.Errorf("The pool available was %d but should be 1", a)
+	}
+
+	other, err := New(func() (*grpc.ClientConn, error) {
+		return &grpc.ClientConn{}, nil
+	}, 1, 1, 0)
+	if err != nil {
+		t.Errorf("The pool returned an error: %s", err.Error())
+	}
+	foreign, err := other.Get(context.Background())
+	if err != nil {
+		t.Errorf("Get returned an error: %s", err.Error())
+	}
+	expectPanic(t, "Close of a conn from a different pool", func() {
+		p.PutAll([]*ClientConn{foreign})
+	})
+}
+
+func TestStrictLifecycleCloseAfterPanic(t *testing.T) {
+	p, err := New(dialTestClient, 1, 1, 0, WithStrictLifecycle())
+	if err != nil {
+		t.Errorf("The pool returned an error: %s", err.Error())
+	}
+
+	client, err := p.Get(context.Background())
+	if err != nil {
+		t.Errorf("Get returned an error: %s", err.Error())
+	}
+	copied := *client
+	client.Close()
+	expectPanic(t, "Close of a copy", func() { copied.Close() })
+
+	closed := make(chan struct{})
+	go func() {
+		p.Close()
+		close(closed)
+	}()
+	select {
+	case <-closed:
+	case <-time.After(time.Second):
+		t.Errorf("Close didn't return after a strict lifecycle panic")
+	}
+}
+
+func TestWithTarget(t *testing.T) {
+	p, err := New(dialTestClient, 0, 1, 0)
+	if err != nil {
+		t.Errorf("The pool returned an error: %s", err.Error())
+	}
+	if target := p.Target(); target != "" {
+		t.Errorf("The target was %q but should be empty", target)
+	}
+	client, err := p.Get(context.Background())
+	if err != nil {
+		t.Errorf("Get returned an error: %s", err.Error())
+	}
+	client.Close()
+	if target := p.Target(); target != "passthrough:///localhost:0" {
+		t.Errorf("The target was %q but should be the client one", target)
+	}
+
+	p, err = New(dialTestClient, 1, 1, 0, WithTarget("dns:///backend:443"))
+	if err != nil {
+		t.Errorf("The pool returned an error: %s", err.Error())
+	}
+	if target := p.Target(); target != "dns:///backend:443" {
+		t.Errorf("The target was %q but should be the configured one", target)
+	}
+}
+
+func TestWithSalvageCanceledDials(t *testing.T) {
+	release := make(chan struct{})
+	p, err := New(func() (*grpc.ClientConn, error) {
+		<-release
+		return dialTestClient()
+	}, 0, 1, 0, WithSalvageCanceledDials())
+	if err != nil {
+		t.Errorf("The pool returned an error: %s", err.Error())
+	}
+	defer p.Close()
+
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Millisecond)
+	defer cancel()
+	if _, err := p.Get(ctx); err != ErrTimeout {
+		t.Errorf("Expected error \"%s\" but got \"%v\"", ErrTimeout, err)
+	}
+	close(release)
+
+	client, err := p.Get(context.Background())
+	if err != nil {
+		t.Errorf("Get returned an error: %s", err.Error())
+	}
+	client.Close()
+	if s := p.Stats(); s.Created != 1 || s.Salvaged != 1 {
+		t.Errorf("The cancelled dial should have been salvaged, stats were %+v", s)
+	}
+}
+
+func TestWithQueueAndDialTimeout(t *testing.T) {
+	release := make(chan struct{})
+	defer close(release)
+	p, err := New(func() (*grpc.ClientConn, error) {
+		<-release
+		return dialTestClient()
+	}, 0, 1, 0, WithQueueTimeout(10*time.Millisecond),
+		WithDialTimeout(10*time.Millisecond))
+	if err != nil {
+		t.Errorf("The pool returned an error: %s", err.Error())
+	}
+
+	// The dial hangs, so the first Get times out dialing and the second one
+	// waiting for the slot held by the dial in progress
+	if _, err := p.Get(context.Background()); err != ErrDialTimeout {
+		t.Errorf("Expected error \"%s\" but got \"%v\"", ErrDialTimeout, err)
+	}
+	_, err = p.Get(context.Background())
+	if err != ErrQueueTimeout {
+		t.Errorf("Expected error \"%s\" but got \"%v\"", ErrQueueTimeout, err)
+	}
+	if !errors.Is(err, ErrTimeout) {
+		t.Errorf("The error \"%v\" should wrap \"%s\"", err, ErrTimeout)
+	}
+}
+
+func TestWithLabeledFactory(t *testing.T) {
+	p, err := New(nil, 1, 1, 0, WithLabeledFactory(
+		func() (*grpc.ClientConn, map[string]string, error) {
+			conn, err := dialTestClient()
+			return conn, map[string]string{"region": "eu"}, err
+		}))
+	if err != nil {
+		t.Errorf("The pool returned an error: %s", err.Error())
+	}
+
+	client, err := p.Get(context.Background())
+	if err != nil {
+		t.Errorf("Get returned an error: %s", err.Error())
+	}
+	labels := client.Labels()
+	if labels["region"] != "eu" {
+		t.Errorf("The labels were %v but should have region eu", labels)
+	}
+	labels["region"] = "us"
+	if r := client.Labels()["region"]; r != "eu" {
+		t.Errorf("Modifying the labels returned should not affect the client, region was %s", r)
+	}
+	client.Close()
+}
+
+func TestWithExpectedTarget(t *testing.T) {
+	_, err := New(dialTestClient, 1, 1, 0, WithExpectedTarget("dns:///backend:443"))
+	if !errors.Is(err, ErrTargetMismatch) {
+		t.Errorf("Expected error \"%s\" but got \"%v\"", ErrTargetMismatch, err)
+	}
+
+	p, err := New(dialTestClient, 1, 1, 0,
+		WithExpectedTarget("passthrough:///localhost:0"))
+	if err != nil {
+		t.Errorf("The pool returned an error: %s", err.Error())
+	}
+	p.Close()
+}
+
+func TestWithFixedConns(t *testing.T) {
+	var conns []*grpc.ClientConn
+	for i := 0; i < 2; i++ {
+		conn, err := dialTestClient()
+		if err != nil {
+			t.Errorf("The client could not be created: %s", err.Error())
+		}
+		conns = append(conns, conn)
+	}
+	p, err := New(nil, 0, 5, 0, WithFixedConns(conns))
+	if err != nil {
+		t.Errorf("The pool returned an error: %s", err.Error())
+	}
+	if c := p.Capacity(); c != 2 {
+		t.Errorf("The pool capacity was %d but should be 2", c)
+	}
+
+	client, err := p.Get(context.Background())
+	if err != nil {
+		t.Errorf("Get returned an error: %s", err.Error())
+	}
+	client2, err := p.Get(context.Background())
+	if err != nil {
+		t.Errorf("Get returned an error: %s", err.Error())
+	}
+	if client.ClientConn != conns[0] || client2.ClientConn != conns[1] {
+		t.Errorf("The pool should have handed out the seeded connections")
+	}
+	client.Unhealthy()
+	client.Close()
+	client2.Close()
+	if _, err := p.Get(context.Background()); err != ErrFixedConns {
+		t.Errorf("Expected error \"%s\" but got \"%v\"", ErrFixedConns, err)
+	}
+
+	p.Close()
+	for _, conn := range conns {
+		if s := conn.GetState(); s != connectivity.Shutdown {
+			t.Errorf("The connection state was %s but should be %s", s, connectivity.Shutdown)
+		}
+	}
+}
+
+func TestWithFixedConnsIdleTimeout(t *testing.T) {
+	conn, err := dialTestClient()
+	if err != nil {
+		t.Errorf("The client could not be created: %s", err.Error())
+	}
+	p, err := New(nil, 0, 0, time.Millisecond, WithFixedConns([]*grpc.ClientConn{conn}))
+	if err != nil {
+		t.Errorf("The pool returned an error: %s", err.Error())
+	}
+	defer p.Close()
+
+	p.RefreshAll()
+	time.Sleep(10 * time.Millisecond)
+	client, err := p.Get(context.Background())
+	if err != nil {
+		t.Errorf("Get returned an error: %s", err.Error())
+	} else if client.ClientConn != conn {
+		t.Errorf("The pool should have handed out the seeded connection")
+	}
+	client.Close()
+
+	// Failing a match, GetWhere can't replace the seeded connection
+	client, err = p.GetWhere(context.Background(), func(ConnInfo) bool { return false })
+	if err != nil {
+		t.Errorf("GetWhere returned an error: %s", err.Error())
+	} else if client.ClientConn != conn {
+		t.Errorf("The pool should have handed out the seeded connection")
+	}
+	client.Close()
+	if s := conn.GetState(); s == connectivity.Shutdown {
+		t.Errorf("The seeded connection shouldn't have been closed")
+	}
+	if _, err := p.Get(context.Background()); err != nil {
+		t.Errorf("Get returned an error: %s", err.Error())
+	}
+}
@@ -4,10 +4,13 @@ package grpcpool
 import (
 	"context"
 	"errors"
+	"fmt"
 	"sync"
+	"sync/atomic"
 	"time"
 
 	"google.golang.org/grpc"
+	"google.golang.org/grpc/connectivity"
 )
 
 var (
@@ -15,35 +18,169 @@ var (
 	ErrClosed = errors.New("grpc pool: client pool is closed")
 	// ErrTimeout is the error when the client pool timed out
 	ErrTimeout = errors.New("grpc pool: client pool timed out")
+	// ErrQueueTimeout is the error when no client was returned to the pool
+	// within the queue timeout. It wraps ErrTimeout
+	ErrQueueTimeout = fmt.Errorf("%w waiting for an available client", ErrTimeout)
+	// ErrDialTimeout is the error when the factory didn't create a client
+	// within the dial timeout. It wraps ErrTimeout
+	ErrDialTimeout = fmt.Errorf("%w dialing a new client", ErrTimeout)
 	// ErrAlreadyClosed is the error when the client conn was already closed
 	ErrAlreadyClosed = errors.New("grpc pool: the connection was already closed")
 	// ErrFullPool is the error when the pool is already full
 	ErrFullPool = errors.New("grpc pool: closing a ClientConn into a full pool")
+	// ErrForeignConn is the error when a client is returned to a pool it
+	// wasn't checked out from
+	ErrForeignConn = errors.New("grpc pool: the connection belongs to another pool")
+	// ErrTargetMismatch is the error when the factory created a client for
+	// another target than the expected one
+	ErrTargetMismatch = errors.New("grpc pool: the connection target is not the expected one")
+	// ErrSingleConn is the error when a pool created with FromConn needs a
+	// new client
+	ErrSingleConn = errors.New("grpc pool: the single connection of the pool can't be redialed")
+	// ErrFixedConns is the error when a pool created with WithFixedConns
+	// needs a new client
+	ErrFixedConns = errors.New("grpc pool: the pool can't dial beyond its fixed connections")
 )
 
 // Factory is a function type creating a grpc client
 type Factory func() (*grpc.ClientConn, error)
 
+// LabeledFactory is a function type creating a grpc client along with the
+// labels describing it (region, version...)
+type LabeledFactory func() (*grpc.ClientConn, map[string]string, error)
+
 // Pool is the grpc client pool
 type Pool struct {
-	clients     chan ClientConn
-	factory     Factory
-	idleTimeout time.Duration
-	mu          sync.RWMutex
+	clients chan ClientConn
+	factory Factory
+	mu      sync.RWMutex
+
+	waitMu     sync.Mutex
+	waiters    []*waiter
+	waitSeq    uint64
+	waitClosed bool
+
+	configMu sync.RWMutex
+	config   config
+
+	strict      bool
+	stacks      bool
+	outMu       sync.Mutex
+	outstanding map[*grpc.ClientConn]checkout
+	nextID      atomic.Uint64
+	checkedOut  atomic.Int64
+	inTransit   atomic.Int64
+	returnMu    sync.Mutex
+	allReturned chan struct{}
+
+	labeledFactory     LabeledFactory
+	defaultCallOptions []grpc.CallOption
+	defaultRPCTimeout  time.Duration
+	perConnRPS         int
+	onReturn           func(recycled bool, reason CloseReason)
+	onCloseError       func(err error)
+	longIdleThreshold  time.Duration
+	onLongIdleReuse    func(info ConnInfo, idleFor time.Duration)
+	target             atomic.Pointer[string]
+	everConnected      atomic.Bool
+	lastDialErr        atomic.Pointer[error]
+	expectedTarget     string
+	salvageDials       bool
+	quiesceWait        bool
+	quiescedUntil      atomic.Int64
+	quiesceMu          sync.Mutex
+	quiesceChanged     chan struct{}
+	drainMode          atomic.Int32
+
+	autoEvictThreshold float64
+	autoEvictWindow    time.Duration
+	ttl                time.Duration
+	onExpired          func()
+	resolveInterval    time.Duration
+	evictInterval      time.Duration
+	resolve            func(target string) ([]string, error)
+	sizeEstimator      func(*grpc.ClientConn) int64
+	maxConnBytes       int64
+	sizeCheckInterval  time.Duration
+	warmupPolicy       WarmupErrorPolicy
+	fixedConns         []*grpc.ClientConn
+	timelineMax        int
+	growthLimit        int
+	burst              atomic.Bool
+	reserved           atomic.Int64
+	provisionWindow    time.Duration
+	provisionThreshold float64
+	onOverProvisioned  func(peak, capacity int)
+	windowPeak         atomic.Int64
+	generation         atomic.Int64
+	unhealthyThreshold int
+	unhealthyCooldown  time.Duration
+	unhealthyStreak    atomic.Int64
+	lastUnhealthy      atomic.Int64
+
+	ctx    context.Context
+	cancel context.CancelFunc
+	wg     sync.WaitGroup
+
+	counters   counters
+	statsCache statsCache
+	reuse      histogram
+	waits      histogram
 }
 
 // ClientConn is the wrapper for a grpc client conn
 type ClientConn struct {
 	*grpc.ClientConn
 	pool      *Pool
+	meta      *connMeta
 	timeUsed  time.Time
 	unhealthy bool
 }
 
+// connMeta holds what the pool tracks about a grpc client conn for its whole
+// life, unlike the ClientConn wrapper which is copied on every checkout
+type connMeta struct {
+	// id, createdAt, labels, limiter, generation and timeline are set at
+	// creation and never modified
+	id         uint64
+	createdAt  time.Time
+	labels     map[string]string
+	limiter    *tokenBucket
+	generation int64
+	timeline   *timeline
+
+	mu sync.Mutex
+	// uses and errors are counted since the last error rate evaluation
+	uses   int64
+	errors int64
+	// totalUses is the number of checkouts since the client was created
+	totalUses int64
+	// version is the last backend version noted for the client
+	version string
+	// addr is the last address noted for the client
+	addr string
+	// lastErrorAt is when the last error was recorded for the client
+	lastErrorAt time.Time
+	// drained is closed once the client is recycled, if DrainWhere waits
+	// for it
+	drained chan struct{}
+}
+
+// noted returns the last backend version noted for the client
+func (m *connMeta) noted() string {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	return m.version
+}
+
 // New creates a new clients pool with the given initial amd maximum capacity,
 // and the timeout for the idle clients. Returns an error if the initial
-// clients could not be created
-func New(factory Factory, init, capacity int, idleTimeout time.Duration) (*Pool, error) {
+// clients could not be created, after closing the ones which were, unless
+// WithWarmupErrorPolicy says otherwise. Optional behaviour can be enabled by passing
+// one or more Option
+func New(factory Factory, init, capacity int, idleTimeout time.Duration,
+	options ...Option) (*Pool, error) {
 	if capacity <= 0 {
 		capacity = 1
 	}
@@ -54,31 +191,63 @@ func New(factory Factory, init, capacity int, idleTimeout time.Duration) (*Pool,
 		init = capacity
 	}
 	p := &Pool{
-		clients:     make(chan ClientConn, capacity),
-		factory:     factory,
-		idleTimeout: idleTimeout,
+		clients: make(chan ClientConn, capacity),
+		factory: factory,
+		config: config{
+			idleTimeout: idleTimeout,
+		},
+	}
+	for _, option := range options {
+		option(p)
+	}
+	if n := len(p.fixedConns); n > 0 {
+		// The seeded connections set the capacity
+		init, capacity = n, n
+		p.clients = make(chan ClientConn, n)
+	}
+	if err := p.fill(init, capacity); err != nil {
+		return nil, err
 	}
-	for i := 0; i < init; i++ {
-		c, err := factory()
-		if err != nil {
-			return nil, err
-		}
 
-		p.clients <- ClientConn{
-			ClientConn: c,
-			pool:       p,
-			timeUsed:   time.Now(),
-		}
+	p.ctx, p.cancel = context.WithCancel(context.Background())
+	if p.autoEvictThreshold > 0 && p.autoEvictWindow > 0 {
+		p.runEvery(p.autoEvictWindow, p.evictOnErrorRate)
 	}
-	// Fill the rest of the pool with empty clients
-	for i := 0; i < capacity-init; i++ {
-		p.clients <- ClientConn{
-			pool: p,
-		}
+	if p.ttl > 0 {
+		p.closeAfterTTL()
+	}
+	if p.resolveInterval > 0 && p.resolve != nil {
+		p.runEvery(p.resolveInterval, p.checkResolve)
+	}
+	if p.evictInterval > 0 {
+		p.runEvery(p.evictInterval, p.evictIdle)
+	}
+	if p.provisionWindow > 0 && p.onOverProvisioned != nil {
+		p.runEvery(p.provisionWindow, p.checkOverProvisioned)
+	}
+	if p.maxConnBytes > 0 && p.sizeEstimator != nil && p.fixedConns == nil {
+		p.runEvery(p.sizeCheckInterval, p.evictOversized)
 	}
 	return p, nil
 }
 
+// FromConn creates a pool of capacity 1 holding conn, so that code written
+// against the pool API can run on a single existing connection. The pool
+// never redials: its factory fails with ErrSingleConn, so a Get following the
+// recycling of conn (e.g. after ClientConn.Unhealthy) returns that error.
+// Closing the pool closes conn
+func FromConn(conn *grpc.ClientConn) *Pool {
+	var taken atomic.Bool
+	// The factory succeeds for the initial client, so New can't fail
+	p, _ := New(func() (*grpc.ClientConn, error) {
+		if taken.Swap(true) {
+			return nil, ErrSingleConn
+		}
+		return conn, nil
+	}, 1, 1, 0)
+	return p
+}
+
 func (p *Pool) getClients() chan ClientConn {
 	p.mu.RLock()
 	defer p.mu.RUnlock()
@@ -87,27 +256,39 @@ func (p *Pool) getClients() chan ClientConn {
 }
 
 // Close empties the pool calling Close on all its clients.
-// You can call Close while there are outstanding clients.
-// It waits for all clients to be returned (Close).
-// The pool channel is then closed, and Get will not be allowed anymore
+// You can call Close while there are outstanding clients: they are closed
+// when returned, with Close returning ErrClosed. CloseWith offers to wait for
+// them or close them right away instead.
+// The shutdown is sequenced so that no background task can touch a client
+// being closed: the pool is first marked closed, so Get is not allowed
+// anymore, then the background tasks are cancelled and waited for, and only
+// then the pool channel is closed and its idle clients closed
 func (p *Pool) Close() {
+	p.close()
+}
+
+// close closes the pool, returning false if it was already closed
+func (p *Pool) close() bool {
 	p.mu.Lock()
 	clients := p.clients
 	p.clients = nil
 	p.mu.Unlock()
 
 	if clients == nil {
-		return
+		return false
 	}
+	p.failWaiters()
+	p.cancel()
+	p.wg.Wait()
 
 	close(clients)
-	for i := 0; i < p.Capacity(); i++ {
-		client := <-clients
+	for client := range clients {
 		if client.ClientConn == nil {
 			continue
 		}
-		client.ClientConn.Close()
+		p.closeConn(client.ClientConn)
 	}
+	return true
 }
 
 // IsClosed returns true if the client pool is closed.
@@ -118,47 +299,318 @@ func (p *Pool) IsClosed() bool {
 // Get will return the next available client. If capacity
 // has not been reached, it will create a new one using the factory. Otherwise,
 // it will wait till the next client becomes available or a timeout.
-// A timeout of 0 is an indefinite wait
+// A timeout of 0 is an indefinite wait. If ctx is already done, Get doesn't
+// take any client and returns an error wrapping both ErrTimeout and ctx.Err()
 func (p *Pool) Get(ctx context.Context) (*ClientConn, error) {
+	return p.get(ctx, true, 0)
+}
+
+// GetNoRecycle is like Get, but hands out the next available client without
+// checking whether it has been idle for longer than the idle timeout. It saves
+// the redial a stale client would cost, for best-effort calls where latency
+// matters more than freshness: the client returned may be stale and its
+// underlying connection may have been dropped by the server or a proxy in the
+// meantime, in which case the RPC will fail or pay for the reconnection
+func (p *Pool) GetNoRecycle(ctx context.Context) (*ClientConn, error) {
+	return p.get(ctx, false, 0)
+}
+
+// get checks out a client, recycling it first if it's stale and recycle is
+// set. pri orders the call among the ones waiting for a client
+func (p *Pool) get(ctx context.Context, recycle bool, pri int) (*ClientConn, error) {
+	clients, err := p.admit(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	cfg := p.currentConfig()
+	if p.draining() == DrainServeWarm {
+		wrapper, err := p.takeWarm(func(ConnInfo) bool { return true })
+		if err != nil {
+			return nil, err
+		}
+		p.notePeak(clients)
+		return p.checkOutFrom(ctx, wrapper, cfg, false)
+	}
+	wrapper, err := p.acquire(ctx, clients, cfg, pri)
+	if err != nil {
+		return nil, err
+	}
+	p.notePeak(clients)
+	return p.checkOutFrom(ctx, wrapper, cfg, recycle)
+}
+
+// admit tells whether a Get may take a client out of the pool, returning the
+// pool channel if so
+func (p *Pool) admit(ctx context.Context) (chan ClientConn, error) {
+	// A client may be available, but a caller who already gave up must not
+	// be handed one
+	if err := ctx.Err(); err != nil {
+		return nil, fmt.Errorf("%w: %w", ErrTimeout, err)
+	}
 	clients := p.getClients()
 	if clients == nil {
 		return nil, ErrClosed
 	}
-
-	wrapper := ClientConn{
-		pool: p,
+	if err := p.waitQuiescence(ctx); err != nil {
+		return nil, err
 	}
-	select {
-	case wrapper = <-clients:
-		// All good
-	case <-ctx.Done():
-		return nil, ErrTimeout
+	if err := p.checkAllUnhealthy(); err != nil {
+		return nil, err
 	}
+	if p.draining() == DrainReject {
+		return nil, ErrDraining
+	}
+	return clients, nil
+}
+
+// checkOutFrom hands out the client taken out of the pool, dialing a new one
+// if it's a placeholder, or if it's stale and recycle is set. While the pool
+// is draining, it never dials: a placeholder is put back and ErrDraining
+// returned, a stale client handed out as it is
+func (p *Pool) checkOutFrom(ctx context.Context, wrapper ClientConn, cfg config,
+	recycle bool) (*ClientConn, error) {
+	// The Get may have been waiting since before the drain started
+	draining := p.draining() != DrainNone
+	if draining && wrapper.ClientConn == nil {
+		p.putBack(wrapper)
+		return nil, ErrDraining
+	}
+	recycle = recycle && !draining
 
 	// If the wrapper is old, close the connection and create a new one. It's
 	// safe to assume that there isn't any newer client as the client we fetched
 	// is the first in the channel
-	idleTimeout := p.idleTimeout
-	if wrapper.ClientConn != nil && idleTimeout > 0 &&
-		wrapper.timeUsed.Add(idleTimeout).Before(time.Now()) {
+	if recycle && p.evictInterval <= 0 && wrapper.ClientConn != nil {
+		if reason := p.recycleReason(wrapper, cfg); reason != ReasonNone {
+			p.recycle(wrapper, reason)
+			wrapper.ClientConn = nil
+			wrapper.meta = nil
+		}
+	}
 
-		wrapper.ClientConn.Close()
-		wrapper.ClientConn = nil
+	if wrapper.ClientConn != nil {
+		p.noteIdleReuse(wrapper)
+	} else {
+		var err error
+		wrapper, err = p.create(ctx, cfg)
+		if errors.Is(err, ErrTimeout) {
+			return nil, err
+		}
+		if err != nil {
+			p.noteHealth(false)
+			return &wrapper, err
+		}
+	}
+	wrapper.meta.mu.Lock()
+	wrapper.meta.uses++
+	wrapper.meta.totalUses++
+	wrapper.meta.mu.Unlock()
+	wrapper.meta.record(EventCheckedOut, ReasonNone, nil)
+	p.checkedOut.Add(1)
+	p.inTransit.Add(-1)
+	if p.outstanding != nil {
+		p.checkOut(wrapper)
+	}
+
+	return &wrapper, nil
+}
+
+// acquire takes the next client, warm or not, out of the pool, waiting for
+// one if needed. The placeholders beyond the growth limit are held back on the
+// way
+func (p *Pool) acquire(ctx context.Context, clients chan ClientConn,
+	cfg config, pri int) (ClientConn, error) {
+	for {
+		client, err := p.acquireSlot(ctx, clients, cfg, pri)
+		if err != nil || client.ClientConn != nil || !p.holdBack() {
+			return client, err
+		}
 	}
+}
 
+// acquireSlot takes the next client, warm or not, out of the pool. If there's
+// none, it queues behind the other waiting Get calls, by priority then
+// arrival, until a client is handed to it
+func (p *Pool) acquireSlot(ctx context.Context, clients chan ClientConn,
+	cfg config, pri int) (ClientConn, error) {
+	p.waitMu.Lock()
+	if p.waitClosed {
+		p.waitMu.Unlock()
+		return ClientConn{}, ErrClosed
+	}
+	if p.draining() != DrainNone {
+		// Drain started since the Get was admitted
+		p.waitMu.Unlock()
+		return ClientConn{}, ErrDraining
+	}
+	if len(p.waiters) == 0 {
+		select {
+		case client, ok := <-clients:
+			if ok {
+				p.inTransit.Add(1)
+			}
+			p.waitMu.Unlock()
+			if !ok {
+				return ClientConn{}, ErrClosed
+			}
+			p.counters.noWait.Add(1)
+			return client, nil
+		default:
+		}
+	}
+	w := p.enqueue(pri)
+	p.waitMu.Unlock()
+
+	p.counters.waitCount.Add(1)
+	start := time.Now()
+	defer func() {
+		waited := time.Since(start)
+		p.counters.waitDuration.Add(int64(waited))
+		p.waits.record(int64(waited))
+	}()
+
+	var queueTimeout <-chan time.Time
+	if cfg.queueTimeout > 0 {
+		timer := time.NewTimer(cfg.queueTimeout)
+		defer timer.Stop()
+		queueTimeout = timer.C
+	}
 	var err error
-	if wrapper.ClientConn == nil {
-		wrapper.ClientConn, err = p.factory()
+	select {
+	case client, ok := <-w.ch:
+		if !ok {
+			return ClientConn{}, w.err
+		}
+		return client, nil
+	case <-ctx.Done():
+		err = ErrTimeout
+	case <-queueTimeout:
+		err = ErrQueueTimeout
+	}
+	p.abandon(w)
+	return ClientConn{}, err
+}
+
+// recycleReason tells why an idle client should be recycled rather than
+// handed out, if it should
+func (p *Pool) recycleReason(client ClientConn, cfg config) CloseReason {
+	if p.fixedConns != nil {
+		// Nothing could replace a seeded connection
+		return ReasonNone
+	}
+	if cfg.idleTimeout > 0 && client.timeUsed.Add(cfg.idleTimeout).Before(time.Now()) {
+		return ReasonIdleTimeout
+	}
+	if cfg.preferredVersion != "" {
+		if v := client.meta.noted(); v != "" && v != cfg.preferredVersion {
+			return ReasonOutdatedVersion
+		}
+	}
+	if p.needsRefresh(client.meta) {
+		return ReasonRefresh
+	}
+	return ReasonNone
+}
+
+// create dials a new client for the slot of a placeholder taken out of the
+// pool. If the dial fails, the placeholder is put back in the pool.
+//
+// With WithSalvageCanceledDials or WithDialTimeout, the dial runs in the
+// background and create returns ErrTimeout as soon as ctx is done, or
+// ErrDialTimeout once the dial timeout elapses. The dial then completes on
+// its own: with salvaging, its client fills the slot in the pool instead of
+// being wasted, otherwise it's closed and a placeholder fills the slot
+func (p *Pool) create(ctx context.Context, cfg config) (ClientConn, error) {
+	if !p.salvageDials && cfg.dialTimeout <= 0 {
+		client, err := p.dial()
 		if err != nil {
 			// If there was an error, we want to put back a placeholder
 			// client in the channel
-			clients <- ClientConn{
-				pool: p,
+			p.putBack(ClientConn{pool: p})
+			return ClientConn{pool: p}, err
+		}
+		return client, nil
+	}
+
+	type result struct {
+		client ClientConn
+		err    error
+	}
+	done := make(chan result)
+	abandoned := make(chan struct{})
+	go func() {
+		client, err := p.dial()
+		select {
+		case done <- result{client, err}:
+			return
+		case <-abandoned:
+		}
+		if err != nil || !p.salvageDials {
+			if err == nil {
+				p.closeConn(client.ClientConn)
 			}
+			p.putBack(ClientConn{pool: p})
+			return
+		}
+		p.counters.salvaged.Add(1)
+		p.putBack(client)
+	}()
+
+	var dialTimeout <-chan time.Time
+	if cfg.dialTimeout > 0 {
+		timer := time.NewTimer(cfg.dialTimeout)
+		defer timer.Stop()
+		dialTimeout = timer.C
+	}
+	select {
+	case r := <-done:
+		if r.err != nil {
+			p.putBack(ClientConn{pool: p})
+			return ClientConn{pool: p}, r.err
 		}
+		return r.client, nil
+	case <-ctx.Done():
+		close(abandoned)
+		return ClientConn{pool: p}, ErrTimeout
+	case <-dialTimeout:
+		close(abandoned)
+		return ClientConn{pool: p}, ErrDialTimeout
+	}
+}
+
+// ID returns the identifier of the client within its pool, as found in
+// ConnInfo. It's 0 for a closed ClientConn
+func (c *ClientConn) ID() uint64 {
+	if c == nil || c.ClientConn == nil || c.meta == nil {
+		return 0
 	}
+	return c.meta.id
+}
+
+// Labels returns a copy of the labels given to the client by the
+// LabeledFactory which created it
+func (c *ClientConn) Labels() map[string]string {
+	if c == nil || c.meta == nil || c.meta.labels == nil {
+		return nil
+	}
+	labels := make(map[string]string, len(c.meta.labels))
+	for k, v := range c.meta.labels {
+		labels[k] = v
+	}
+	return labels
+}
 
-	return &wrapper, err
+// NoteVersion records the version of the backend the client is connected to,
+// as observed in a response (e.g. from a trailer). With WithPreferredVersion,
+// Get recycles the clients noted with another version than the preferred one
+func (c *ClientConn) NoteVersion(v string) {
+	if c == nil || c.meta == nil {
+		return
+	}
+	c.meta.mu.Lock()
+	c.meta.version = v
+	c.meta.mu.Unlock()
 }
 
 // Unhealthy marks the client conn as unhealthy, so that the connection
@@ -174,46 +626,290 @@ func (c *ClientConn) Close() error {
 		return nil
 	}
 	if c.ClientConn == nil {
+		if c.pool != nil && c.pool.strict {
+			panic("grpc pool: strict lifecycle: ClientConn closed twice")
+		}
+		if c.pool != nil && c.pool.onCloseError != nil {
+			c.pool.onCloseError(ErrAlreadyClosed)
+		}
 		return ErrAlreadyClosed
 	}
-	if c.pool.IsClosed() {
-		return ErrClosed
+
+	// The read lock is released by a defer, not to leave the pool locked
+	// when put panics in strict mode
+	reason, err := func() (CloseReason, error) {
+		c.pool.mu.RLock()
+		defer c.pool.mu.RUnlock()
+
+		return c.pool.put(c)
+	}()
+
+	c.pool.returned(reason, err)
+	if err != nil && c.pool.onCloseError != nil {
+		c.pool.onCloseError(err)
+	}
+	return err
+}
+
+// CloseKeepWarm returns a ClientConn to the pool like Close, for a caller that
+// just confirmed the client works (e.g. with a successful health check RPC).
+// Like with Close, its idle time restarts from now. On top of that, the
+// checkouts and errors counted for WithAutoEvictOnErrorRate are reset, so
+// past errors don't get a freshly validated client recycled. The backend
+// version noted for the client is kept
+func (c *ClientConn) CloseKeepWarm() error {
+	if c != nil && c.meta != nil {
+		c.meta.mu.Lock()
+		c.meta.uses, c.meta.errors = 0, 0
+		c.meta.mu.Unlock()
+	}
+	return c.Close()
+}
+
+// put returns a client to the pool, telling why it was closed instead of
+// pooled if it was. The caller must hold the read lock, which guarantees the
+// clients channel isn't closed while sending to it
+func (p *Pool) put(c *ClientConn) (CloseReason, error) {
+	if p.outstanding != nil {
+		p.checkIn(c.ClientConn)
+	}
+	if p.clients == nil {
+		// CloseWith(CloseForce) may have closed it already
+		if c.GetState() != connectivity.Shutdown {
+			p.closeConn(c.ClientConn)
+			c.meta.record(EventRecycled, ReasonPoolClosed, nil)
+		}
+		c.ClientConn = nil
+		p.noteReturned()
+		return ReasonPoolClosed, ErrClosed
 	}
 
 	// We're cloning the wrapper so we can set ClientConn to nil in the one
 	// used by the user
 	wrapper := ClientConn{
-		pool:       c.pool,
+		pool:       p,
 		ClientConn: c.ClientConn,
+		meta:       c.meta,
 		timeUsed:   time.Now(),
 	}
+	reason := ReasonNone
+	c.meta.record(EventReturned, ReasonNone, nil)
+	p.noteHealth(!c.unhealthy)
 	if c.unhealthy {
-		wrapper.ClientConn.Close()
+		reason = ReasonUnhealthy
+	} else if c.meta.draining() {
+		reason = ReasonDrained
+	}
+	if reason != ReasonNone {
+		p.recycle(wrapper, reason)
 		wrapper.ClientConn = nil
+		wrapper.meta = nil
 	}
-	select {
-	case c.pool.clients <- wrapper:
-		// All good
-	default:
-		return ErrFullPool
+	if !p.send(wrapper) {
+		p.counters.fullPool.Add(1)
+		return reason, ErrFullPool
 	}
 
 	c.ClientConn = nil // Mark as closed
-	return nil
+	p.noteReturned()
+	return reason, nil
+}
+
+// putBack puts a client the pool holds the slot of back in the pool, ending
+// its transit. If the pool was closed meanwhile, the client is closed instead
+func (p *Pool) putBack(client ClientConn) {
+	p.mu.RLock()
+	defer p.mu.RUnlock()
+	defer p.inTransit.Add(-1)
+
+	if p.clients != nil && p.send(client) {
+		return
+	}
+	if client.ClientConn != nil {
+		p.closeConn(client.ClientConn)
+	}
+}
+
+// PutAll returns several clients to the pool at once, taking the pool lock a
+// single time, typically to release a batch used to fan out RPCs. Nil and
+// already closed clients are skipped. The errors of the others, like
+// ErrFullPool or ErrForeignConn, are aggregated into the returned error along
+// with the index of the client they concern
+func (p *Pool) PutAll(conns []*ClientConn) error {
+	type outcome struct {
+		reason CloseReason
+		err    error
+	}
+	var (
+		errs     []error
+		outcomes []outcome
+	)
+	defer func() {
+		for _, o := range outcomes {
+			p.returned(o.reason, o.err)
+		}
+	}()
+
+	p.mu.RLock()
+	defer p.mu.RUnlock()
+
+	for i, c := range conns {
+		if c == nil || c.ClientConn == nil {
+			if c != nil && p.strict {
+				panic("grpc pool: strict lifecycle: ClientConn closed twice")
+			}
+			continue
+		}
+		if c.pool != p {
+			if p.strict {
+				panic("grpc pool: strict lifecycle: ClientConn was not checked out from this pool")
+			}
+			errs = append(errs, fmt.Errorf("client %d: %w", i, ErrForeignConn))
+			continue
+		}
+		reason, err := p.put(c)
+		if err != nil {
+			errs = append(errs, fmt.Errorf("client %d: %w", i, err))
+		}
+		outcomes = append(outcomes, outcome{reason, err})
+	}
+	return errors.Join(errs...)
+}
+
+// dial creates a new client using the factory
+func (p *Pool) dial() (ClientConn, error) {
+	var (
+		conn   *grpc.ClientConn
+		labels map[string]string
+		err    error
+	)
+	p.counters.factoryCalls.Add(1)
+	start := time.Now()
+	if p.labeledFactory != nil {
+		conn, labels, err = p.labeledFactory()
+	} else {
+		conn, err = p.factory()
+	}
+	p.counters.dialDuration.Add(int64(time.Since(start)))
+	if err != nil {
+		p.counters.dialErrors.Add(1)
+		p.lastDialErr.Store(&err)
+		return ClientConn{pool: p}, err
+	}
+	p.counters.created.Add(1)
+	if p.expectedTarget != "" && conn.Target() != p.expectedTarget {
+		p.closeConn(conn)
+		p.counters.dialErrors.Add(1)
+		err := fmt.Errorf("%w: got %q instead of %q",
+			ErrTargetMismatch, conn.Target(), p.expectedTarget)
+		p.lastDialErr.Store(&err)
+		return ClientConn{pool: p}, err
+	}
+	if p.target.Load() == nil {
+		target := conn.Target()
+		p.target.CompareAndSwap(nil, &target)
+	}
+	p.everConnected.Store(true)
+
+	meta := &connMeta{
+		id:         p.nextID.Add(1),
+		createdAt:  time.Now(),
+		generation: p.generation.Load(),
+	}
+	if p.perConnRPS > 0 {
+		meta.limiter = newTokenBucket(p.perConnRPS)
+	}
+	if p.timelineMax > 0 {
+		meta.timeline = newTimeline(p.timelineMax)
+		meta.record(EventCreated, ReasonNone, nil)
+	}
+	if len(labels) > 0 {
+		meta.labels = make(map[string]string, len(labels))
+		for k, v := range labels {
+			meta.labels[k] = v
+		}
+	}
+	return ClientConn{
+		ClientConn: conn,
+		pool:       p,
+		meta:       meta,
+		timeUsed:   meta.createdAt,
+	}, nil
+}
+
+// closeConn closes a client created by the pool
+func (p *Pool) closeConn(conn *grpc.ClientConn) {
+	conn.Close()
+	p.counters.closed.Add(1)
+}
+
+// recycle closes a client for reason, counting it
+func (p *Pool) recycle(client ClientConn, reason CloseReason) {
+	p.closeConn(client.ClientConn)
+
+	client.meta.mu.Lock()
+	uses := client.meta.totalUses
+	client.meta.mu.Unlock()
+	p.reuse.record(uses)
+	client.meta.record(EventRecycled, reason, nil)
+	client.meta.notifyDrained()
+
+	switch reason {
+	case ReasonUnhealthy:
+		p.counters.unhealthy.Add(1)
+	case ReasonIdleTimeout:
+		p.counters.evicted.Add(1)
+	case ReasonOutdatedVersion:
+		p.counters.outdated.Add(1)
+	case ReasonErrorRate:
+		p.counters.autoEvicted.Add(1)
+	case ReasonStaleAddress:
+		p.counters.staleAddress.Add(1)
+	case ReasonOversized:
+		p.counters.oversized.Add(1)
+	case ReasonRefresh:
+		p.counters.refreshed.Add(1)
+	case ReasonDrained:
+		p.counters.drained.Add(1)
+	case ReasonNoMatch:
+		p.counters.noMatch.Add(1)
+	}
+}
+
+// Target returns the target of the pool clients: the one given with
+// WithTarget, or else the target of the first client the pool created. It's
+// available whatever the state of the clients, which makes it a stable label
+// for the downstream of the pool in logs and metrics. It's empty if no target
+// was configured and no client was created yet
+func (p *Pool) Target() string {
+	if target := p.target.Load(); target != nil {
+		return *target
+	}
+	return ""
+}
+
+// EverConnected tells whether the factory ever created a client successfully,
+// for readiness probes to tell a pool which never reached its backend from a
+// degraded one. With lazy grpc clients, a successful factory call doesn't
+// guarantee the connection itself was established
+func (p *Pool) EverConnected() bool {
+	return p.everConnected.Load()
 }
 
-// Capacity returns the capacity
+// Capacity returns the capacity. With WithGrowthLimit, it's the burst
+// ceiling, the slots held back included
 func (p *Pool) Capacity() int {
-	if p.IsClosed() {
+	if p == nil {
 		return 0
 	}
-	return cap(p.clients)
+	// A closed pool has no clients channel, of capacity 0
+	return cap(p.getClients())
 }
 
 // Available returns the number of currently unused clients
 func (p *Pool) Available() int {
-	if p.IsClosed() {
+	if p == nil {
 		return 0
 	}
-	return len(p.clients)
+	return len(p.getClients())
 }
@@ -2,12 +2,22 @@ package grpcpool
 
 import (
 	"context"
+	"errors"
 	"testing"
 	"time"
 
 	"google.golang.org/grpc"
+	"google.golang.org/grpc/connectivity"
+	"google.golang.org/grpc/credentials/insecure"
 )
 
+// dialTestClient is a factory creating real clients, which can be closed,
+// that never connect unless used
+func dialTestClient() (*grpc.ClientConn, error) {
+	return grpc.NewClient("passthrough:///localhost:0",
+		grpc.WithTransportCredentials(insecure.NewCredentials()))
+}
+
 func TestNew(t *testing.T) {
 	p, err := New(func() (*grpc.ClientConn, error) {
 		return &grpc.ClientConn{}, nil
@@ -111,9 +121,224 @@ func TestTimeout(t *testing.T) {
 	// We want to fetch a second one, with a timeout. If the timeout was
 	// ommitted, the pool would wait indefinitely as it'd wait for another
 	// client to get back into the queue
-	ctx, _ := context.WithDeadline(context.Background(), time.Now().Add(10*time.Millisecond))
+	ctx, cancel := context.WithDeadline(context.Background(), time.Now().Add(10*time.Millisecond))
+	defer cancel()
 	_, err2 := p.Get(ctx)
 	if err2 != ErrTimeout {
 		t.Errorf("Expected error \"%s\" but got \"%s\"", ErrTimeout, err2.Error())
 	}
 }
+
+func TestGetCanceledContext(t *testing.T) {
+	p, err := New(dialTestClient, 1, 1, 0)
+	if err != nil {
+		t.Errorf("The pool returned an error: %s", err.Error())
+	}
+	defer p.Close()
+
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	for i := 0; i < 10; i++ {
+		client, err := p.Get(ctx)
+		if !errors.Is(err, ErrTimeout) || !errors.Is(err, context.Canceled) {
+			t.Errorf("Expected error \"%s\" but got \"%v\"", ErrTimeout, err)
+		}
+		if client != nil {
+			t.Errorf("Get returned a client for a canceled context")
+		}
+	}
+	if a := p.Available(); a != 1 {
+		t.Errorf("The pool available was %d but should be 1", a)
+	}
+}
+
+func TestFromConn(t *testing.T) {
+	conn, err := dialTestClient()
+	if err != nil {
+		t.Errorf("The client could not be created: %s", err.Error())
+	}
+	p := FromConn(conn)
+
+	client, err := p.Get(context.Background())
+	if err != nil {
+		t.Errorf("Get returned an error: %s", err.Error())
+	}
+	if client.ClientConn != conn {
+		t.Errorf("The pool should have handed out the connection it was created from")
+	}
+	client.Unhealthy()
+	client.Close()
+	if _, err := p.Get(context.Background()); err != ErrSingleConn {
+		t.Errorf("Expected error \"%s\" but got \"%v\"", ErrSingleConn, err)
+	}
+	p.Close()
+
+	conn, err = dialTestClient()
+	if err != nil {
+		t.Errorf("The client could not be created: %s", err.Error())
+	}
+	FromConn(conn).Close()
+	if s := conn.GetState(); s != connectivity.Shutdown {
+		t.Errorf("The connection state was %s but should be %s", s, connectivity.Shutdown)
+	}
+}
+
+func TestSingleUnhealthyFailedRedial(t *testing.T) {
+	for _, options := range [][]Option{nil, {WithDialTimeout(time.Second)}} {
+		down := false
+		p, err := New(func() (*grpc.ClientConn, error) {
+			if down {
+				return nil, errors.New("backend down")
+			}
+			return dialTestClient()
+		}, 1, 1, 0, options...)
+		if err != nil {
+			t.Errorf("The pool returned an error: %s", err.Error())
+		}
+
+		client, err := p.Get(context.Background())
+		if err != nil {
+			t.Errorf("Get returned an error: %s", err.Error())
+		}
+		down = true
+		client.Unhealthy()
+		client.Close()
+
+		for i := 0; i < 3; i++ {
+			if _, err := p.Get(context.Background()); err == nil {
+				t.Errorf("Get should have returned the dial error")
+			}
+			if a := p.Available(); a != 1 {
+				t.Errorf("The pool available was %d but should be 1", a)
+			}
+		}
+
+		// The slot is dialed again once the backend is back
+		down = false
+		client, err = p.Get(context.Background())
+		if err != nil {
+			t.Errorf("Get returned an error: %s", err.Error())
+		}
+		client.Close()
+		if s := p.Stats(); s.Available != 1 || s.Created != 2 || s.DialErrors != 3 {
+			t.Errorf("The stats were %+v", s)
+		}
+		p.Close()
+	}
+}
+
+func TestEverConnected(t *testing.T) {
+	down := true
+	p, err := New(func() (*grpc.ClientConn, error) {
+		if down {
+			return nil, errors.New("backend down")
+		}
+		return dialTestClient()
+	}, 0, 1, 0)
+	if err != nil {
+		t.Errorf("The pool returned an error: %s", err.Error())
+	}
+	defer p.Close()
+
+	if _, err := p.Get(context.Background()); err == nil {
+		t.Errorf("Get should have returned the dial error")
+	}
+	if p.EverConnected() {
+		t.Errorf("The pool shouldn't have connected yet")
+	}
+	down = false
+	client, err := p.Get(context.Background())
+	if err != nil {
+		t.Errorf("Get returned an error: %s", err.Error())
+	}
+	client.Unhealthy()
+	client.Close()
+	if !p.EverConnected() {
+		t.Errorf("The pool should have connected")
+	}
+}
+
+func TestPutAll(t *testing.T) {
+	p, err := New(dialTestClient, 0, 3, 0)
+	if err != nil {
+		t.Errorf("The pool returned an error: %s", err.Error())
+	}
+	other, err := New(dialTestClient, 0, 1, 0)
+	if err != nil {
+		t.Errorf("The pool returned an error: %s", err.Error())
+	}
+
+	var conns []*ClientConn
+	for i := 0; i < 3; i++ {
+		client, err := p.Get(context.Background())
+		if err != nil {
+			t.Errorf("Get returned an error: %s", err.Error())
+		}
+		conns = append(conns, client)
+	}
+	foreign, err := other.Get(context.Background())
+	if err != nil {
+		t.Errorf("Get returned an error: %s", err.Error())
+	}
+	conns[0].Close()
+
+	err = p.PutAll(append(conns, foreign, nil))
+	if !errors.Is(err, ErrForeignConn) {
+		t.Errorf("Expected error \"%s\" but got \"%v\"", ErrForeignConn, err)
+	}
+	if a := p.Available(); a != 3 {
+		t.Errorf("The pool available was %d but should be 3", a)
+	}
+	if err := p.PutAll(conns); err != nil {
+		t.Errorf("PutAll of closed clients returned an error: %s", err.Error())
+	}
+}
+
+func TestGetNoRecycle(t *testing.T) {
+	p, err := New(dialTestClient, 1, 1, time.Millisecond)
+	if err != nil {
+		t.Errorf("The pool returned an error: %s", err.Error())
+	}
+	time.Sleep(5 * time.Millisecond)
+
+	client, err := p.GetNoRecycle(context.Background())
+	if err != nil {
+		t.Errorf("GetNoRecycle returned an error: %s", err.Error())
+	}
+	client.Close()
+	if s := p.Stats(); s.Evicted != 0 || s.Created != 1 {
+		t.Errorf("GetNoRecycle should not recycle, stats were %+v", s)
+	}
+
+	time.Sleep(5 * time.Millisecond)
+	client, err = p.Get(context.Background())
+	if err != nil {
+		t.Errorf("Get returned an error: %s", err.Error())
+	}
+	client.Close()
+	if s := p.Stats(); s.Evicted != 1 || s.Created != 2 {
+		t.Errorf("Get should recycle the stale client, stats were %+v", s)
+	}
+}
+
+func TestPreferredVersion(t *testing.T) {
+	p, err := New(dialTestClient, 0, 2, 0, WithPreferredVersion("v2"))
+	if err != nil {
+		t.Errorf("The pool returned an error: %s", err.Error())
+	}
+
+	cl1, _ := p.Get(context.Background())
+	cl2, _ := p.Get(context.Background())
+	cl1.NoteVersion("v1")
+	cl1.Close()
+	cl2.Close()
+
+	// The v1 client is recycled, the one with no noted version is kept
+	cl1, _ = p.Get(context.Background())
+	cl2, _ = p.Get(context.Background())
+	cl1.Close()
+	cl2.Close()
+	if s := p.Stats(); s.Outdated != 1 || s.Created != 3 {
+		t.Errorf("Only the v1 client should be recycled, stats were %+v", s)
+	}
+}
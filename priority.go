@@ -0,0 +1,114 @@
+package grpcpool
+
+import (
+	"context"
+	"sort"
+)
+
+// GetWithPriority is like Get, but when no client is available, the calls
+// with a higher pri are handed the clients returned to the pool before the
+// ones with a lower pri, whatever their arrival. Calls of the same priority
+// are served in arrival order. Get has priority 0
+func (p *Pool) GetWithPriority(ctx context.Context, pri int) (*ClientConn, error) {
+	return p.get(ctx, true, pri)
+}
+
+// Waiting returns the number of Get calls waiting for a client to be returned
+// to the pool
+func (p *Pool) Waiting() int {
+	p.waitMu.Lock()
+	defer p.waitMu.Unlock()
+
+	return len(p.waiters)
+}
+
+// waiter is a Get call waiting for a client to be returned to the pool
+type waiter struct {
+	pri int
+	seq uint64
+	// ch is handed the client, or closed along with the pool or when it
+	// starts draining, err telling which. It's buffered so that handing a
+	// client never blocks
+	ch  chan ClientConn
+	err error
+}
+
+// enqueue adds a waiter of priority pri to the queue, behind the waiters of
+// the same or a higher priority. The caller must hold waitMu
+func (p *Pool) enqueue(pri int) *waiter {
+	p.waitSeq++
+	w := &waiter{pri: pri, seq: p.waitSeq, ch: make(chan ClientConn, 1)}
+	i := sort.Search(len(p.waiters), func(i int) bool {
+		return p.waiters[i].pri < pri
+	})
+	p.waiters = append(p.waiters, nil)
+	copy(p.waiters[i+1:], p.waiters[i:])
+	p.waiters[i] = w
+	return w
+}
+
+// abandon removes a waiter which gave up from the queue. If it was handed a
+// client meanwhile, the client is passed on to the next waiter or put back in
+// the pool
+func (p *Pool) abandon(w *waiter) {
+	p.waitMu.Lock()
+	for i, other := range p.waiters {
+		if other == w {
+			p.waiters = append(p.waiters[:i], p.waiters[i+1:]...)
+			p.waitMu.Unlock()
+			return
+		}
+	}
+	p.waitMu.Unlock()
+
+	if client, ok := <-w.ch; ok {
+		p.putBack(client)
+	}
+}
+
+// send hands a client to the first waiter in the queue, or puts it in the
+// pool if none is waiting, returning false if the pool is full. A client
+// handed to a waiter is in transit until checked out. The caller must hold
+// the read lock, with the pool not closed
+func (p *Pool) send(client ClientConn) bool {
+	p.waitMu.Lock()
+	defer p.waitMu.Unlock()
+
+	return p.sendLocked(client)
+}
+
+// sendLocked is send for a caller already holding waitMu
+func (p *Pool) sendLocked(client ClientConn) bool {
+	if len(p.waiters) > 0 {
+		w := p.waiters[0]
+		p.waiters = p.waiters[1:]
+		p.inTransit.Add(1)
+		w.ch <- client
+		return true
+	}
+	select {
+	case p.clients <- client:
+		return true
+	default:
+		return false
+	}
+}
+
+// failWaiters wakes up the waiters with ErrClosed once the pool is closed,
+// and prevents new ones from queuing
+func (p *Pool) failWaiters() {
+	p.waitMu.Lock()
+	defer p.waitMu.Unlock()
+
+	p.waitClosed = true
+	p.wakeWaiters(ErrClosed)
+}
+
+// wakeWaiters wakes up all the waiters with err. The caller must hold waitMu
+func (p *Pool) wakeWaiters(err error) {
+	for _, w := range p.waiters {
+		w.err = err
+		close(w.ch)
+	}
+	p.waiters = nil
+}
@@ -0,0 +1,76 @@
+package grpcpool
+
+import (
+	"context"
+	"testing"
+	"time"
+)
+
+func waitForWaiters(p *Pool, n int) {
+	for p.Waiting() < n {
+		time.Sleep(time.Millisecond)
+	}
+}
+
+func TestGetWithPriority(t *testing.T) {
+	p, err := New(dialTestClient, 1, 1, 0)
+	if err != nil {
+		t.Errorf("The pool returned an error: %s", err.Error())
+	}
+	defer p.Close()
+
+	client, err := p.Get(context.Background())
+	if err != nil {
+		t.Errorf("Get returned an error: %s", err.Error())
+	}
+
+	served := make(chan int, 3)
+	for i, pri := range []int{0, -1, 10} {
+		go func() {
+			conn, err := p.GetWithPriority(context.Background(), pri)
+			if err != nil {
+				t.Errorf("GetWithPriority returned an error: %s", err.Error())
+				return
+			}
+			served <- pri
+			conn.Close()
+		}()
+		waitForWaiters(p, i+1)
+	}
+	client.Close()
+
+	for _, want := range []int{10, 0, -1} {
+		if got := <-served; got != want {
+			t.Errorf("The pool served priority %d but should have served %d", got, want)
+		}
+	}
+}
+
+func TestGetWithPriorityAbandoned(t *testing.T) {
+	p, err := New(dialTestClient, 1, 1, 0)
+	if err != nil {
+		t.Errorf("The pool returned an error: %s", err.Error())
+	}
+
+	client, err := p.Get(context.Background())
+	if err != nil {
+		t.Errorf("Get returned an error: %s", err.Error())
+	}
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Millisecond)
+	defer cancel()
+	if _, err := p.GetWithPriority(ctx, 1); err != ErrTimeout {
+		t.Errorf("Expected error \"%s\" but got \"%v\"", ErrTimeout, err)
+	}
+
+	closed := make(chan error)
+	go func() {
+		_, err := p.Get(context.Background())
+		closed <- err
+	}()
+	waitForWaiters(p, 1)
+	p.Close()
+	if err := <-closed; err != ErrClosed {
+		t.Errorf("Expected error \"%s\" but got \"%v\"", ErrClosed, err)
+	}
+	client.Close()
+}
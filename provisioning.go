@@ -0,0 +1,53 @@
+package grpcpool
+
+import (
+	"sync/atomic"
+	"time"
+)
+
+// WithOnOverProvisioned calls fn at the end of every window during which the
+// peak number of clients in use stayed below threshold times the capacity,
+// e.g. 0.5 for half the capacity, hinting that the pool could be smaller.
+// fn is given the peak of the window and the capacity, and runs in the
+// background, Close waiting for it to return
+func WithOnOverProvisioned(window time.Duration, threshold float64,
+	fn func(peak, capacity int)) Option {
+	return func(p *Pool) {
+		p.provisionWindow = window
+		p.provisionThreshold = threshold
+		p.onOverProvisioned = fn
+	}
+}
+
+// PeakInUse returns the highest number of clients in use at once since the
+// pool was created
+func (p *Pool) PeakInUse() int {
+	return int(p.counters.peakInUse.Load())
+}
+
+// notePeak records the number of clients in use after a checkout
+func (p *Pool) notePeak(clients chan ClientConn) {
+	inUse := int64(cap(clients)-len(clients)) - p.reserved.Load()
+	for _, peak := range []*atomic.Int64{&p.counters.peakInUse, &p.windowPeak} {
+		for {
+			old := peak.Load()
+			if inUse <= old || peak.CompareAndSwap(old, inUse) {
+				break
+			}
+		}
+	}
+}
+
+// checkOverProvisioned calls the OverProvisioned hook if the peak of the
+// window ending is below the threshold, and starts a new window
+func (p *Pool) checkOverProvisioned() {
+	capacity := p.Capacity()
+	if capacity == 0 {
+		return
+	}
+	// The clients still in use open the next window
+	peak := int(p.windowPeak.Swap(int64(p.Stats().InUse)))
+	if float64(peak) < p.provisionThreshold*float64(capacity) {
+		p.onOverProvisioned(peak, capacity)
+	}
+}
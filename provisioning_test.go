@@ -0,0 +1,46 @@
+package grpcpool
+
+import (
+	"context"
+	"testing"
+	"time"
+)
+
+func TestWithOnOverProvisioned(t *testing.T) {
+	type report struct{ peak, capacity int }
+	reports := make(chan report, 100)
+	p, err := New(dialTestClient, 0, 4, 0, WithOnOverProvisioned(10*time.Millisecond, 0.5,
+		func(peak, capacity int) {
+			reports <- report{peak, capacity}
+		}))
+	if err != nil {
+		t.Errorf("The pool returned an error: %s", err.Error())
+	}
+
+	client, err := p.Get(context.Background())
+	if err != nil {
+		t.Errorf("Get returned an error: %s", err.Error())
+	}
+	if r := <-reports; r.peak != 1 || r.capacity != 4 {
+		t.Errorf("The pool reported a peak of %d for %d but should be 1 for 4", r.peak, r.capacity)
+	}
+	client2, err := p.Get(context.Background())
+	if err != nil {
+		t.Errorf("Get returned an error: %s", err.Error())
+	}
+	if n := p.PeakInUse(); n != 2 {
+		t.Errorf("The pool peak in use was %d but should be 2", n)
+	}
+
+	// Half the capacity in use isn't over-provisioned
+	time.Sleep(20 * time.Millisecond)
+	p.Close()
+	close(reports)
+	for r := range reports {
+		if r.peak >= 2 {
+			t.Errorf("The pool reported a peak of %d but shouldn't have", r.peak)
+		}
+	}
+	client.Close()
+	client2.Close()
+}
@@ -0,0 +1,85 @@
+package grpcpool
+
+import (
+	"context"
+	"errors"
+	"time"
+)
+
+// ErrQuiescing is the error when Get is called while the pool is quiescing
+var ErrQuiescing = errors.New("grpc pool: client pool is quiescing")
+
+// WithQuiesceWait makes Get wait for the end of a quiescence started by
+// QuiesceFor, within the limits of its context, instead of failing with
+// ErrQuiescing
+func WithQuiesceWait() Option {
+	return func(p *Pool) {
+		p.quiesceWait = true
+	}
+}
+
+// QuiesceFor stops the pool from handing out clients for d, e.g. during a
+// planned backend restart, after which it resumes by itself. Meanwhile Get
+// fails with ErrQuiescing, or waits for the end of the quiescence with
+// WithQuiesceWait, failing with ErrTimeout if its context is done first.
+// The clients already checked out are unaffected. Calling QuiesceFor again
+// replaces the end of the quiescence, for the Get calls already waiting too,
+// QuiesceFor(0) resumes right away
+func (p *Pool) QuiesceFor(d time.Duration) {
+	var until int64
+	if d > 0 {
+		until = time.Now().Add(d).UnixNano()
+	}
+	p.quiescedUntil.Store(until)
+
+	// Wake up the Get calls waiting for the previous end
+	p.quiesceMu.Lock()
+	defer p.quiesceMu.Unlock()
+
+	if p.quiesceChanged != nil {
+		close(p.quiesceChanged)
+		p.quiesceChanged = nil
+	}
+}
+
+// quiesceChange returns a channel closed when QuiesceFor is next called
+func (p *Pool) quiesceChange() <-chan struct{} {
+	p.quiesceMu.Lock()
+	defer p.quiesceMu.Unlock()
+
+	if p.quiesceChanged == nil {
+		p.quiesceChanged = make(chan struct{})
+	}
+	return p.quiesceChanged
+}
+
+// waitQuiescence returns once the pool isn't quiescing anymore, or with an
+// error if Get should fail meanwhile
+func (p *Pool) waitQuiescence(ctx context.Context) error {
+	for {
+		// Taken before loading the end, not to miss a change in between
+		changed := p.quiesceChange()
+		until := p.quiescedUntil.Load()
+		if until == 0 {
+			return nil
+		}
+		left := time.Until(time.Unix(0, until))
+		if left <= 0 {
+			p.quiescedUntil.CompareAndSwap(until, 0)
+			return nil
+		}
+		if !p.quiesceWait {
+			return ErrQuiescing
+		}
+
+		timer := time.NewTimer(left)
+		select {
+		case <-timer.C:
+		case <-changed:
+			timer.Stop()
+		case <-ctx.Done():
+			timer.Stop()
+			return ErrTimeout
+		}
+	}
+}
@@ -0,0 +1,75 @@
+package grpcpool
+
+import (
+	"context"
+	"testing"
+	"time"
+)
+
+func TestQuiesceFor(t *testing.T) {
+	p, err := New(dialTestClient, 1, 1, 0)
+	if err != nil {
+		t.Errorf("The pool returned an error: %s", err.Error())
+	}
+	defer p.Close()
+
+	p.QuiesceFor(20 * time.Millisecond)
+	if _, err := p.Get(context.Background()); err != ErrQuiescing {
+		t.Errorf("Expected error \"%s\" but got \"%v\"", ErrQuiescing, err)
+	}
+	time.Sleep(30 * time.Millisecond)
+	client, err := p.Get(context.Background())
+	if err != nil {
+		t.Errorf("Get returned an error: %s", err.Error())
+	}
+	client.Close()
+}
+
+func TestQuiesceWait(t *testing.T) {
+	p, err := New(dialTestClient, 1, 1, 0, WithQuiesceWait())
+	if err != nil {
+		t.Errorf("The pool returned an error: %s", err.Error())
+	}
+	defer p.Close()
+
+	p.QuiesceFor(20 * time.Millisecond)
+	// The end of the quiescence window itself, rather than a lower bound on
+	// the time Get takes, which doesn't hold on a loaded machine
+	end := time.Unix(0, p.quiescedUntil.Load())
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Millisecond)
+	defer cancel()
+	if _, err := p.Get(ctx); err != ErrTimeout {
+		t.Errorf("Expected error \"%s\" but got \"%v\"", ErrTimeout, err)
+	}
+
+	client, err := p.Get(context.Background())
+	if err != nil {
+		t.Errorf("Get returned an error: %s", err.Error())
+	}
+	client.Close()
+	if d := time.Until(end); d > 0 {
+		t.Errorf("Get returned %s before the end of the quiescence", d)
+	}
+}
+
+func TestQuiesceResumeEarly(t *testing.T) {
+	p, err := New(dialTestClient, 1, 1, 0, WithQuiesceWait())
+	if err != nil {
+		t.Errorf("The pool returned an error: %s", err.Error())
+	}
+	defer p.Close()
+
+	p.QuiesceFor(time.Hour)
+	go func() {
+		time.Sleep(10 * time.Millisecond)
+		p.QuiesceFor(0)
+	}()
+
+	ctx, cancel := context.WithTimeout(context.Background(), time.Second)
+	defer cancel()
+	client, err := p.Get(ctx)
+	if err != nil {
+		t.Errorf("Get returned an error: %s", err.Error())
+	}
+	client.Close()
+}
@@ -0,0 +1,90 @@
+package grpcpool
+
+import (
+	"context"
+	"sync"
+	"time"
+
+	"google.golang.org/grpc/status"
+)
+
+// WithPerConnRateLimit limits each client of the pool to rps RPCs per second
+// through the AsClientConn adapter, with bursts of up to rps RPCs. An RPC over
+// the limit waits, holding its client, until the client allows it or its
+// context is done, in which case it fails with the matching status error.
+// Stats.RateLimited counts the RPCs which had to wait. RPCs performed directly
+// on a client from Get aren't limited
+func WithPerConnRateLimit(rps int) Option {
+	return func(p *Pool) {
+		p.perConnRPS = rps
+	}
+}
+
+// tokenBucket is a token bucket rate limiter
+type tokenBucket struct {
+	rate float64 // tokens per second, also the bucket size
+
+	mu     sync.Mutex
+	tokens float64
+	last   time.Time
+}
+
+func newTokenBucket(rps int) *tokenBucket {
+	return &tokenBucket{
+		rate:   float64(rps),
+		tokens: float64(rps),
+		last:   time.Now(),
+	}
+}
+
+// reserve takes a token if one is available, otherwise it returns how long
+// to wait for the next one
+func (b *tokenBucket) reserve() time.Duration {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	now := time.Now()
+	b.tokens += now.Sub(b.last).Seconds() * b.rate
+	if b.tokens > b.rate {
+		b.tokens = b.rate
+	}
+	b.last = now
+	if b.tokens >= 1 {
+		b.tokens--
+		return 0
+	}
+	return time.Duration((1 - b.tokens) / b.rate * float64(time.Second))
+}
+
+// wait blocks until a token is taken or ctx is done, telling whether it had
+// to wait
+func (b *tokenBucket) wait(ctx context.Context) (bool, error) {
+	waited := false
+	for {
+		d := b.reserve()
+		if d == 0 {
+			return waited, nil
+		}
+		waited = true
+
+		timer := time.NewTimer(d)
+		select {
+		case <-timer.C:
+		case <-ctx.Done():
+			timer.Stop()
+			return waited, status.FromContextError(ctx.Err()).Err()
+		}
+	}
+}
+
+// rateLimit waits for the client to allow one more RPC
+func (c *ClientConn) rateLimit(ctx context.Context) error {
+	if c.meta == nil || c.meta.limiter == nil {
+		return nil
+	}
+	waited, err := c.meta.limiter.wait(ctx)
+	if waited {
+		c.pool.counters.rateLimited.Add(1)
+	}
+	return err
+}
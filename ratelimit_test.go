@@ -0,0 +1,32 @@
+package grpcpool
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	healthpb "google.golang.org/grpc/health/grpc_health_v1"
+)
+
+func TestWithPerConnRateLimit(t *testing.T) {
+	p, err := New(newTestServer(t), 1, 1, 0, WithPerConnRateLimit(10))
+	if err != nil {
+		t.Errorf("The pool returned an error: %s", err.Error())
+	}
+	defer p.Close()
+
+	// The burst goes through, the next call waits for a token
+	client := healthpb.NewHealthClient(p.AsClientConn())
+	start := time.Now()
+	for i := 0; i < 11; i++ {
+		if _, err := client.Check(context.Background(), &healthpb.HealthCheckRequest{}); err != nil {
+			t.Errorf("Check returned an error: %s", err.Error())
+		}
+	}
+	if d := time.Since(start); d < 50*time.Millisecond {
+		t.Errorf("The calls took %s, the rate limit wasn't applied", d)
+	}
+	if s := p.Stats(); s.RateLimited != 1 {
+		t.Errorf("%d calls were rate limited but should be 1", s.RateLimited)
+	}
+}
@@ -0,0 +1,82 @@
+package grpcpool
+
+import (
+	"context"
+
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/connectivity"
+)
+
+// ReadinessResult is the outcome of ReadinessCheck, fit to be encoded as the
+// JSON body of a readiness endpoint
+type ReadinessResult struct {
+	// OK tells whether the pool is ready to serve: it isn't closed, it
+	// connected at least once, it isn't failing fast with ErrAllUnhealthy,
+	// and its idle warm clients aren't all in TRANSIENT_FAILURE
+	OK bool `json:"ok"`
+	// Closed tells whether the pool is closed
+	Closed bool `json:"closed"`
+	// EverConnected is the value of EverConnected
+	EverConnected bool `json:"ever_connected"`
+	// AllUnhealthy tells whether Get fails fast with ErrAllUnhealthy
+	AllUnhealthy bool `json:"all_unhealthy"`
+	// Warm is the number of idle clients which aren't placeholders
+	Warm int `json:"warm"`
+	// InUse is the number of clients checked out
+	InUse int `json:"in_use"`
+	// States counts the idle warm clients by connectivity state, once probed
+	States map[string]int `json:"states"`
+	// LastDialError is the error of the last failed factory call
+	LastDialError string `json:"last_dial_error,omitempty"`
+}
+
+// ReadinessCheck probes the idle clients of the pool and reports on its
+// readiness. The probe is lightweight and leaves the clients in the pool:
+// the ones which never connected are asked to, then their state is awaited
+// until they're connected or failed, or until ctx is done. The clients
+// checked out meanwhile keep probing on their own
+func (p *Pool) ReadinessCheck(ctx context.Context) ReadinessResult {
+	r := ReadinessResult{
+		Closed:        p.IsClosed(),
+		EverConnected: p.EverConnected(),
+		AllUnhealthy:  p.checkAllUnhealthy() != nil,
+		InUse:         p.Stats().InUse,
+		States:        map[string]int{},
+	}
+	if err := p.lastDialErr.Load(); err != nil {
+		r.LastDialError = (*err).Error()
+	}
+
+	var conns []*grpc.ClientConn
+	p.sweep(func(client ClientConn) ClientConn {
+		if client.ClientConn != nil {
+			conns = append(conns, client.ClientConn)
+		}
+		return client
+	})
+	// The states are awaited out of the pool lock, not to block Get
+	for _, conn := range conns {
+		r.States[probe(ctx, conn).String()]++
+	}
+	r.Warm = len(conns)
+
+	r.OK = !r.Closed && r.EverConnected && !r.AllUnhealthy &&
+		(r.Warm == 0 || r.States[connectivity.TransientFailure.String()] < r.Warm)
+	return r
+}
+
+// probe connects conn if it's idle and returns its state once settled, or
+// when ctx is done
+func probe(ctx context.Context, conn *grpc.ClientConn) connectivity.State {
+	state := conn.GetState()
+	if state == connectivity.Idle {
+		conn.Connect()
+	}
+	for state == connectivity.Idle || state == connectivity.Connecting {
+		if !conn.WaitForStateChange(ctx, state) {
+			break
+		}
+		state = conn.GetState()
+	}
+	return state
+}
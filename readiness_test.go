@@ -0,0 +1,36 @@
+package grpcpool
+
+import (
+	"context"
+	"encoding/json"
+	"testing"
+	"time"
+)
+
+func TestReadinessCheck(t *testing.T) {
+	p, err := New(newTestServer(t), 1, 2, 0)
+	if err != nil {
+		t.Errorf("The pool returned an error: %s", err.Error())
+	}
+	defer p.Close()
+
+	ctx, cancel := context.WithTimeout(context.Background(), time.Second)
+	defer cancel()
+	r := p.ReadinessCheck(ctx)
+	if !r.OK || r.Warm != 1 || r.States["READY"] != 1 {
+		t.Errorf("The readiness was %+v", r)
+	}
+	if _, err := json.Marshal(r); err != nil {
+		t.Errorf("The readiness could not be encoded: %s", err.Error())
+	}
+
+	down, err := New(dialTestClient, 1, 1, 0)
+	if err != nil {
+		t.Errorf("The pool returned an error: %s", err.Error())
+	}
+	defer down.Close()
+	r = down.ReadinessCheck(ctx)
+	if r.OK || r.States["TRANSIENT_FAILURE"] != 1 {
+		t.Errorf("The readiness was %+v", r)
+	}
+}
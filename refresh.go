@@ -0,0 +1,51 @@
+package grpcpool
+
+// RefreshAll requests the replacement of all the clients created so far, e.g.
+// once certificates were rotated. The idle ones are recycled when Get takes
+// them out of the pool, as with the idle timeout. The ones checked out are
+// replaced cooperatively by their holder calling ClientConn.MaybeRefresh,
+// or recycled when taken out of the pool again once returned. The RPCs
+// through AsClientConn take a client from the pool each, so they switch to
+// new clients right away
+func (p *Pool) RefreshAll() {
+	p.generation.Add(1)
+}
+
+// needsRefresh tells whether the client was created before the last
+// RefreshAll
+func (p *Pool) needsRefresh(meta *connMeta) bool {
+	return meta != nil && meta.generation < p.generation.Load()
+}
+
+// MaybeRefresh replaces the client in place with a new one if a refresh was
+// requested with Pool.RefreshAll since it was created, so that a caller
+// holding a client for long can follow maintenance without returning it. The
+// old connection is closed: MaybeRefresh must be called at a safe point,
+// between RPCs, and copies of the wrapper are left with the closed
+// connection. If the new client can't be dialed, the current one is kept and
+// the dial error returned. It does nothing while the pool is draining
+func (c *ClientConn) MaybeRefresh() error {
+	if c == nil || c.ClientConn == nil || c.pool == nil {
+		return ErrAlreadyClosed
+	}
+	p := c.pool
+	if !p.needsRefresh(c.meta) || p.draining() != DrainNone {
+		return nil
+	}
+
+	fresh, err := p.dial()
+	if err != nil {
+		return err
+	}
+	if p.outstanding != nil {
+		p.checkIn(c.ClientConn)
+		p.checkOut(fresh)
+	}
+	p.recycle(*c, ReasonRefresh)
+	c.ClientConn, c.meta = fresh.ClientConn, fresh.meta
+	c.meta.mu.Lock()
+	c.meta.uses++
+	c.meta.totalUses++
+	c.meta.mu.Unlock()
+	return nil
+}
@@ -0,0 +1,51 @@
+package grpcpool
+
+import (
+	"context"
+	"testing"
+)
+
+func TestRefreshAll(t *testing.T) {
+	p, err := New(dialTestClient, 2, 2, 0, WithCheckoutTracking(false))
+	if err != nil {
+		t.Errorf("The pool returned an error: %s", err.Error())
+	}
+	defer p.Close()
+
+	held, err := p.Get(context.Background())
+	if err != nil {
+		t.Errorf("Get returned an error: %s", err.Error())
+	}
+	if err := held.MaybeRefresh(); err != nil {
+		t.Errorf("MaybeRefresh returned an error: %s", err.Error())
+	}
+	id := held.ID()
+	if id != 1 {
+		t.Errorf("The client %d shouldn't have been refreshed", id)
+	}
+
+	p.RefreshAll()
+	if err := held.MaybeRefresh(); err != nil {
+		t.Errorf("MaybeRefresh returned an error: %s", err.Error())
+	}
+	if held.ID() == id {
+		t.Errorf("The client %d should have been refreshed", id)
+	}
+	if out := p.CheckedOut(); len(out) != 1 || out[0].ID != held.ID() {
+		t.Errorf("The checked out clients were %+v", out)
+	}
+
+	// The idle client is recycled on checkout
+	client, err := p.Get(context.Background())
+	if err != nil {
+		t.Errorf("Get returned an error: %s", err.Error())
+	}
+	if client.ID() == 2 {
+		t.Errorf("The idle client 2 should have been refreshed")
+	}
+	client.Close()
+	held.Close()
+	if s := p.Stats(); s.Refreshed != 2 {
+		t.Errorf("%d clients were refreshed but should be 2", s.Refreshed)
+	}
+}
@@ -0,0 +1,64 @@
+package grpcpool
+
+import (
+	"time"
+)
+
+// WithResolveCheck re-resolves the pool target every interval with resolve,
+// and recycles the idle clients whose address is no longer among the ones
+// resolved, so that the pool follows DNS changes. The next Get then dials a
+// new client, to an up to date address.
+//
+// The address of a client is the one of its last RPC through the AsClientConn
+// adapter, or the one given to ClientConn.NoteAddr. It must have the same
+// format as the ones returned by resolve, typically "ip:port". The clients
+// with no known address are left alone, as are all clients when resolve
+// fails or returns no address
+func WithResolveCheck(interval time.Duration,
+	resolve func(target string) ([]string, error)) Option {
+	return func(p *Pool) {
+		p.resolveInterval = interval
+		p.resolve = resolve
+	}
+}
+
+// NoteAddr records the address the client is connected to, as observed in
+// the peer of an RPC, for WithResolveCheck
+func (c *ClientConn) NoteAddr(addr string) {
+	if c == nil || c.meta == nil {
+		return
+	}
+	c.meta.mu.Lock()
+	c.meta.addr = addr
+	c.meta.mu.Unlock()
+}
+
+// checkResolve recycles the idle clients connected to an address the target
+// no longer resolves to
+func (p *Pool) checkResolve() {
+	addrs, err := p.resolve(p.Target())
+	if err != nil || len(addrs) == 0 {
+		return
+	}
+	current := make(map[string]struct{}, len(addrs))
+	for _, addr := range addrs {
+		current[addr] = struct{}{}
+	}
+
+	p.sweep(func(client ClientConn) ClientConn {
+		if client.ClientConn == nil {
+			return client
+		}
+		client.meta.mu.Lock()
+		addr := client.meta.addr
+		client.meta.mu.Unlock()
+		if _, ok := current[addr]; addr == "" || ok {
+			return client
+		}
+
+		p.recycle(client, ReasonStaleAddress)
+		return ClientConn{
+			pool: p,
+		}
+	})
+}
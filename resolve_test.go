@@ -0,0 +1,44 @@
+package grpcpool
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	healthpb "google.golang.org/grpc/health/grpc_health_v1"
+)
+
+func TestWithResolveCheck(t *testing.T) {
+	resolved := make(chan []string, 1)
+	p, err := New(newTestServer(t), 1, 1, 0, WithResolveCheck(5*time.Millisecond,
+		func(string) ([]string, error) {
+			select {
+			case addrs := <-resolved:
+				return addrs, nil
+			default:
+				return nil, nil
+			}
+		}))
+	if err != nil {
+		t.Errorf("The pool returned an error: %s", err.Error())
+	}
+	defer p.Close()
+
+	// An RPC through the adapter records the client address
+	client := healthpb.NewHealthClient(p.AsClientConn())
+	if _, err := client.Check(context.Background(), &healthpb.HealthCheckRequest{}); err != nil {
+		t.Errorf("Check returned an error: %s", err.Error())
+	}
+
+	resolved <- []string{"10.0.0.1:443"}
+	deadline := time.Now().Add(time.Second)
+	for p.Stats().StaleAddress == 0 && time.Now().Before(deadline) {
+		time.Sleep(5 * time.Millisecond)
+	}
+	if s := p.Stats(); s.StaleAddress != 1 {
+		t.Errorf("%d clients were recycled but should be 1", s.StaleAddress)
+	}
+	if a := p.Available(); a != 1 {
+		t.Errorf("The pool available was %d but should be 1", a)
+	}
+}
@@ -0,0 +1,56 @@
+package grpcpool
+
+import (
+	"time"
+
+	"google.golang.org/grpc"
+)
+
+// sizeCheckInterval is how often the idle clients are checked against
+// WithMaxConnBytes by default
+const sizeCheckInterval = 30 * time.Second
+
+// WithConnSizeEstimator sets the function estimating the memory held by a
+// client, in bytes, for WithMaxConnBytes. grpc doesn't expose the size of its
+// buffers, so the estimate is up to the caller, e.g. from the traffic it saw
+// on the client
+func WithConnSizeEstimator(estimate func(*grpc.ClientConn) int64) Option {
+	return func(p *Pool) {
+		p.sizeEstimator = estimate
+	}
+}
+
+// WithMaxConnBytes recycles the idle clients whose size, as estimated by the
+// function given to WithConnSizeEstimator, exceeds n bytes. A background
+// loop checks the idle clients every 30 seconds, keeping the estimator off
+// the path of Get, and replaces the offenders with placeholders so that the
+// next Get dials a fresh client. Checked out clients are checked once they
+// are returned. It's a no-op without an estimator
+func WithMaxConnBytes(n int64) Option {
+	return func(p *Pool) {
+		p.maxConnBytes = n
+		if p.sizeCheckInterval == 0 {
+			p.sizeCheckInterval = sizeCheckInterval
+		}
+	}
+}
+
+// oversized tells whether the estimated size of a client exceeds the maximum
+func (p *Pool) oversized(client ClientConn) bool {
+	if p.maxConnBytes <= 0 || p.sizeEstimator == nil {
+		return false
+	}
+	return p.sizeEstimator(client.ClientConn) > p.maxConnBytes
+}
+
+// evictOversized recycles the idle clients whose estimated size exceeds the
+// maximum
+func (p *Pool) evictOversized() {
+	p.sweep(func(client ClientConn) ClientConn {
+		if client.ClientConn == nil || !p.oversized(client) {
+			return client
+		}
+		p.recycle(client, ReasonOversized)
+		return ClientConn{pool: p}
+	})
+}
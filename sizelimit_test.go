@@ -0,0 +1,52 @@
+package grpcpool
+
+import (
+	"context"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"google.golang.org/grpc"
+)
+
+func TestWithMaxConnBytes(t *testing.T) {
+	var size atomic.Int64
+	p, err := New(dialTestClient, 1, 1, 0,
+		WithConnSizeEstimator(func(*grpc.ClientConn) int64 { return size.Load() }),
+		WithMaxConnBytes(1024),
+		func(p *Pool) { p.sizeCheckInterval = 5 * time.Millisecond })
+	if err != nil {
+		t.Errorf("The pool returned an error: %s", err.Error())
+	}
+	defer p.Close()
+
+	client, err := p.Get(context.Background())
+	if err != nil {
+		t.Errorf("Get returned an error: %s", err.Error())
+	}
+	id := client.ID()
+	size.Store(2048)
+	// Get doesn't check the size, and neither is the client checked out
+	time.Sleep(20 * time.Millisecond)
+	if s := p.Stats(); s.Oversized != 0 {
+		t.Errorf("%d clients were recycled but should be 0", s.Oversized)
+	}
+	client.Close()
+
+	deadline := time.Now().Add(time.Second)
+	for p.Stats().Oversized == 0 && time.Now().Before(deadline) {
+		time.Sleep(5 * time.Millisecond)
+	}
+	if s := p.Stats(); s.Oversized != 1 {
+		t.Errorf("%d clients were recycled but should be 1", s.Oversized)
+	}
+	size.Store(0)
+	client, err = p.Get(context.Background())
+	if err != nil {
+		t.Errorf("Get returned an error: %s", err.Error())
+	}
+	if client.ID() == id {
+		t.Errorf("The oversized client %d should have been recycled", id)
+	}
+	client.Close()
+}
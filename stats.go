@@ -0,0 +1,350 @@
+package grpcpool
+
+import (
+	"encoding/json"
+	"math/bits"
+	"sync"
+	"sync/atomic"
+	"time"
+)
+
+// counters holds the cumulative counters of a pool
+type counters struct {
+	created      atomic.Int64
+	closed       atomic.Int64
+	evicted      atomic.Int64
+	unhealthy    atomic.Int64
+	fullPool     atomic.Int64
+	dialErrors   atomic.Int64
+	autoEvicted  atomic.Int64
+	salvaged     atomic.Int64
+	outdated     atomic.Int64
+	rateLimited  atomic.Int64
+	staleAddress atomic.Int64
+	oversized    atomic.Int64
+	refreshed    atomic.Int64
+	drained      atomic.Int64
+	noMatch      atomic.Int64
+	factoryCalls atomic.Int64
+	dialDuration atomic.Int64
+	peakInUse    atomic.Int64
+	noWait       atomic.Int64
+	waitCount    atomic.Int64
+	waitDuration atomic.Int64
+
+	// createdAtReset and closedAtReset are the values of created and closed
+	// at the last churn counters reset
+	createdAtReset atomic.Int64
+	closedAtReset  atomic.Int64
+}
+
+// Stats is a snapshot of the state of a pool. Gauges reflect the pool at the
+// time of the snapshot, counters are cumulative since the pool was created
+type Stats struct {
+	// Capacity is the maximum number of clients
+	Capacity int `json:"capacity"`
+	// Available is the number of clients, warm or not, waiting in the pool
+	Available int `json:"available"`
+	// InUse is the number of clients currently checked out
+	InUse int `json:"in_use"`
+	// Waiting is the number of Get calls waiting for a client to be returned
+	Waiting int `json:"waiting"`
+
+	// Created is the number of clients created by the factory
+	Created int64 `json:"created"`
+	// Closed is the number of clients closed by the pool
+	Closed int64 `json:"closed"`
+	// Evicted is the number of clients closed for being idle too long
+	Evicted int64 `json:"evicted"`
+	// Unhealthy is the number of clients closed for being marked unhealthy
+	Unhealthy int64 `json:"unhealthy"`
+	// FullPool is the number of Close calls that hit a full pool
+	FullPool int64 `json:"full_pool"`
+	// DialErrors is the number of failed factory calls
+	DialErrors int64 `json:"dial_errors"`
+	// AutoEvicted is the number of clients closed for their error rate
+	AutoEvicted int64 `json:"auto_evicted"`
+	// Salvaged is the number of clients dialed for a cancelled Get that
+	// were pooled for the next callers
+	Salvaged int64 `json:"salvaged"`
+	// Outdated is the number of clients closed for being connected to
+	// another backend version than the preferred one
+	Outdated int64 `json:"outdated"`
+	// RateLimited is the number of adapter RPCs which waited for their client
+	// rate limit
+	RateLimited int64 `json:"rate_limited"`
+	// StaleAddress is the number of clients closed for being connected to
+	// an address the target doesn't resolve to anymore
+	StaleAddress int64 `json:"stale_address"`
+	// Oversized is the number of clients recycled for exceeding
+	// WithMaxConnBytes
+	Oversized int64 `json:"oversized"`
+	// Refreshed is the number of clients replaced after RefreshAll
+	Refreshed int64 `json:"refreshed"`
+	// Drained is the number of clients recycled by DrainWhere
+	Drained int64 `json:"drained"`
+	// NoMatch is the number of clients recycled by GetWhere failing a match
+	NoMatch int64 `json:"no_match"`
+	// WaitCount is the number of Get calls which had to wait for a client
+	WaitCount int64 `json:"wait_count"`
+	// WaitDuration is the total time Get calls waited for a client
+	WaitDuration time.Duration `json:"wait_duration"`
+	// DialDuration is the total time spent in the factory, whether the dials
+	// succeeded or not
+	DialDuration time.Duration `json:"dial_duration"`
+}
+
+// Stats returns a snapshot of the pool state
+func (p *Pool) Stats() Stats {
+	capacity, available := p.Capacity(), p.Available()
+	inUse := 0
+	if capacity > 0 {
+		inUse = capacity - available - int(p.reserved.Load())
+	}
+	return Stats{
+		Capacity:     capacity,
+		Available:    available,
+		InUse:        inUse,
+		Waiting:      p.Waiting(),
+		Created:      p.counters.created.Load(),
+		Closed:       p.counters.closed.Load(),
+		Evicted:      p.counters.evicted.Load(),
+		Unhealthy:    p.counters.unhealthy.Load(),
+		FullPool:     p.counters.fullPool.Load(),
+		DialErrors:   p.counters.dialErrors.Load(),
+		AutoEvicted:  p.counters.autoEvicted.Load(),
+		Salvaged:     p.counters.salvaged.Load(),
+		Outdated:     p.counters.outdated.Load(),
+		RateLimited:  p.counters.rateLimited.Load(),
+		StaleAddress: p.counters.staleAddress.Load(),
+		Oversized:    p.counters.oversized.Load(),
+		Refreshed:    p.counters.refreshed.Load(),
+		Drained:      p.counters.drained.Load(),
+		NoMatch:      p.counters.noMatch.Load(),
+		WaitCount:    p.counters.waitCount.Load(),
+		WaitDuration: time.Duration(p.counters.waitDuration.Load()),
+		DialDuration: time.Duration(p.counters.dialDuration.Load()),
+	}
+}
+
+// StatsDelta holds the change of the cumulative counters of a pool between
+// two Stats snapshots
+type StatsDelta struct {
+	CreatedDelta      int64         `json:"created_delta"`
+	ClosedDelta       int64         `json:"closed_delta"`
+	EvictedDelta      int64         `json:"evicted_delta"`
+	UnhealthyDelta    int64         `json:"unhealthy_delta"`
+	FullPoolDelta     int64         `json:"full_pool_delta"`
+	DialErrorsDelta   int64         `json:"dial_errors_delta"`
+	AutoEvictedDelta  int64         `json:"auto_evicted_delta"`
+	SalvagedDelta     int64         `json:"salvaged_delta"`
+	OutdatedDelta     int64         `json:"outdated_delta"`
+	RateLimitedDelta  int64         `json:"rate_limited_delta"`
+	StaleAddressDelta int64         `json:"stale_address_delta"`
+	OversizedDelta    int64         `json:"oversized_delta"`
+	RefreshedDelta    int64         `json:"refreshed_delta"`
+	DrainedDelta      int64         `json:"drained_delta"`
+	NoMatchDelta      int64         `json:"no_match_delta"`
+	WaitCountDelta    int64         `json:"wait_count_delta"`
+	WaitDurationDelta time.Duration `json:"wait_duration_delta"`
+	DialDurationDelta time.Duration `json:"dial_duration_delta"`
+}
+
+// StatsSince returns how much the cumulative counters grew since the prev
+// snapshot was taken, making rates straightforward to compute for callers
+// polling Stats
+func (p *Pool) StatsSince(prev Stats) StatsDelta {
+	s := p.Stats()
+	return StatsDelta{
+		CreatedDelta:      s.Created - prev.Created,
+		ClosedDelta:       s.Closed - prev.Closed,
+		EvictedDelta:      s.Evicted - prev.Evicted,
+		UnhealthyDelta:    s.Unhealthy - prev.Unhealthy,
+		FullPoolDelta:     s.FullPool - prev.FullPool,
+		DialErrorsDelta:   s.DialErrors - prev.DialErrors,
+		AutoEvictedDelta:  s.AutoEvicted - prev.AutoEvicted,
+		SalvagedDelta:     s.Salvaged - prev.Salvaged,
+		OutdatedDelta:     s.Outdated - prev.Outdated,
+		RateLimitedDelta:  s.RateLimited - prev.RateLimited,
+		StaleAddressDelta: s.StaleAddress - prev.StaleAddress,
+		OversizedDelta:    s.Oversized - prev.Oversized,
+		RefreshedDelta:    s.Refreshed - prev.Refreshed,
+		DrainedDelta:      s.Drained - prev.Drained,
+		NoMatchDelta:      s.NoMatch - prev.NoMatch,
+		WaitCountDelta:    s.WaitCount - prev.WaitCount,
+		WaitDurationDelta: s.WaitDuration - prev.WaitDuration,
+		DialDurationDelta: s.DialDuration - prev.DialDuration,
+	}
+}
+
+// statsCache holds the last JSON encoding of the pool Stats
+type statsCache struct {
+	ttl time.Duration
+
+	mu      sync.Mutex
+	json    []byte
+	expires time.Time
+}
+
+// WithStatsCacheTTL makes StatsJSON reuse its last encoding for up to ttl
+// instead of encoding the Stats on every call, for pools scraped frequently
+func WithStatsCacheTTL(ttl time.Duration) Option {
+	return func(p *Pool) {
+		p.statsCache.ttl = ttl
+	}
+}
+
+// StatsJSON returns the JSON encoding of the pool Stats. With
+// WithStatsCacheTTL the encoding is cached and may be up to the TTL old. The
+// returned slice is shared between callers and must not be modified
+func (p *Pool) StatsJSON() []byte {
+	c := &p.statsCache
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	now := time.Now()
+	if c.json != nil && now.Before(c.expires) {
+		return c.json
+	}
+	// Stats only holds numbers, it can't fail to encode
+	c.json, _ = json.Marshal(p.Stats())
+	c.expires = now.Add(c.ttl)
+	return c.json
+}
+
+// FactoryCalls returns the number of times the pool called its factory,
+// whether the dial succeeded or not
+func (p *Pool) FactoryCalls() int {
+	return int(p.counters.factoryCalls.Load())
+}
+
+// CreatedSinceReset returns the number of clients created by the factory since
+// the last ResetChurnCounters, or since the pool was created
+func (p *Pool) CreatedSinceReset() int {
+	return int(p.counters.created.Load() - p.counters.createdAtReset.Load())
+}
+
+// ClosedSinceReset returns the number of clients closed by the pool since the
+// last ResetChurnCounters, or since the pool was created
+func (p *Pool) ClosedSinceReset() int {
+	return int(p.counters.closed.Load() - p.counters.closedAtReset.Load())
+}
+
+// ResetChurnCounters restarts the counts of CreatedSinceReset and
+// ClosedSinceReset from zero. The cumulative counters of Stats are unaffected
+func (p *Pool) ResetChurnCounters() {
+	p.counters.createdAtReset.Store(p.counters.created.Load())
+	p.counters.closedAtReset.Store(p.counters.closed.Load())
+}
+
+// histogram counts values in power of two buckets, bounding its memory
+// whatever the values recorded
+type histogram struct {
+	mu      sync.Mutex
+	buckets [65]int
+}
+
+// record counts v, in bucket bits.Len64(v)
+func (h *histogram) record(v int64) {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+
+	h.buckets[bits.Len64(uint64(v))]++
+}
+
+// snapshot returns the buckets up to the last non empty one
+func (h *histogram) snapshot() []int {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+
+	last := len(h.buckets) - 1
+	for last >= 0 && h.buckets[last] == 0 {
+		last--
+	}
+	return append([]int(nil), h.buckets[:last+1]...)
+}
+
+// reset empties the buckets
+func (h *histogram) reset() {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+
+	h.buckets = [65]int{}
+}
+
+// ReuseHistogram returns how many times the clients recycled by the pool had
+// been checked out over their life, to tune the recycling settings. Index 0
+// counts the clients recycled without ever being checked out, index i the
+// ones checked out between 2^(i-1) and 2^i-1 times. The slice stops at the
+// last non empty bucket.
+//
+// Only the clients recycled (idle, unhealthy, outdated, error rate) are
+// counted, not the ones closed along with the pool
+func (p *Pool) ReuseHistogram() []int {
+	return p.reuse.snapshot()
+}
+
+// ResetReuseHistogram empties the histogram returned by ReuseHistogram
+func (p *Pool) ResetReuseHistogram() {
+	p.reuse.reset()
+}
+
+// Pressure returns the demand on the pool relative to its capacity, as
+// (InUse + Waiting) / Capacity. It's 0 when idle, 1 when all the clients are
+// in use and no Get is waiting, and above 1 when Get calls queue beyond the
+// capacity: 1.5 means half the capacity is waiting on top of a full pool. It's
+// 0 for a closed pool
+func (p *Pool) Pressure() float64 {
+	s := p.Stats()
+	if s.Capacity == 0 {
+		return 0
+	}
+	return float64(s.InUse+s.Waiting) / float64(s.Capacity)
+}
+
+// PoolStats mirrors the shape of sql.DBStats, for dashboards shared with
+// database and other connection pools
+type PoolStats struct {
+	// MaxOpenConnections is the capacity of the pool
+	MaxOpenConnections int
+	// OpenConnections is the number of clients created and not closed yet,
+	// whether in use or idle
+	OpenConnections int
+	// InUse is the number of clients checked out
+	InUse int
+	// Idle is the number of warm clients waiting in the pool
+	Idle int
+
+	// WaitCount is the number of Get calls which had to wait for a client
+	WaitCount int64
+	// WaitDuration is the total time Get calls waited for a client
+	WaitDuration time.Duration
+	// MaxIdleClosed is always 0: the pool keeps all its idle clients up to
+	// its capacity
+	MaxIdleClosed int64
+	// MaxIdleTimeClosed is the number of clients closed for being idle for
+	// longer than the idle timeout
+	MaxIdleTimeClosed int64
+	// MaxLifetimeClosed is always 0: the pool has no maximum lifetime
+	MaxLifetimeClosed int64
+}
+
+// DBStyleStats returns the pool stats under the names of sql.DBStats
+func (p *Pool) DBStyleStats() PoolStats {
+	s := p.Stats()
+	open := int(s.Created - s.Closed)
+	idle := open - s.InUse
+	if idle < 0 {
+		// Clients being dialed are counted in use but not open yet
+		idle = 0
+	}
+	return PoolStats{
+		MaxOpenConnections: s.Capacity,
+		OpenConnections:    open,
+		InUse:              s.InUse,
+		Idle:               idle,
+		WaitCount:          s.WaitCount,
+		WaitDuration:       s.WaitDuration,
+		MaxIdleTimeClosed:  s.Evicted,
+	}
+}
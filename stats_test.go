@@ -0,0 +1,266 @@
+package grpcpool
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"testing"
+	"time"
+
+	"google.golang.org/grpc"
+)
+
+func TestStats(t *testing.T) {
+	p, err := New(func() (*grpc.ClientConn, error) {
+		return &grpc.ClientConn{}, nil
+	}, 1, 3, 0)
+	if err != nil {
+		t.Errorf("The pool returned an error: %s", err.Error())
+	}
+
+	client, err := p.Get(context.Background())
+	if err != nil {
+		t.Errorf("Get returned an error: %s", err.Error())
+	}
+	if s := p.Stats(); s.Capacity != 3 || s.Available != 2 || s.InUse != 1 {
+		t.Errorf("The stats gauges were %+v", s)
+	}
+	if s := p.Stats(); s.Created != 1 {
+		t.Errorf("The pool created %d clients but should have created 1", s.Created)
+	}
+	client.Close()
+	if s := p.Stats(); s.InUse != 0 {
+		t.Errorf("The pool had %d clients in use but should have 0", s.InUse)
+	}
+}
+
+func TestStatsSince(t *testing.T) {
+	p, err := New(dialTestClient, 1, 2, 0)
+	if err != nil {
+		t.Errorf("The pool returned an error: %s", err.Error())
+	}
+	prev := p.Stats()
+
+	client, err := p.Get(context.Background())
+	if err != nil {
+		t.Errorf("Get returned an error: %s", err.Error())
+	}
+	client.Unhealthy()
+	client.Close()
+
+	d := p.StatsSince(prev)
+	if d.CreatedDelta != 0 || d.ClosedDelta != 1 || d.UnhealthyDelta != 1 {
+		t.Errorf("The stats delta was %+v", d)
+	}
+}
+
+func TestStatsJSON(t *testing.T) {
+	p, err := New(dialTestClient, 1, 2, 0, WithStatsCacheTTL(time.Hour))
+	if err != nil {
+		t.Errorf("The pool returned an error: %s", err.Error())
+	}
+
+	var stats Stats
+	if err := json.Unmarshal(p.StatsJSON(), &stats); err != nil {
+		t.Errorf("StatsJSON was not valid JSON: %s", err.Error())
+	}
+	if stats.Available != 2 {
+		t.Errorf("The pool available was %d but should be 2", stats.Available)
+	}
+
+	// The cached encoding is served until it expires
+	client, err := p.Get(context.Background())
+	if err != nil {
+		t.Errorf("Get returned an error: %s", err.Error())
+	}
+	defer client.Close()
+	if err := json.Unmarshal(p.StatsJSON(), &stats); err != nil {
+		t.Errorf("StatsJSON was not valid JSON: %s", err.Error())
+	}
+	if stats.Available != 2 {
+		t.Errorf("The cached available was %d but should be 2", stats.Available)
+	}
+}
+
+func TestChurnCounters(t *testing.T) {
+	p, err := New(dialTestClient, 2, 2, 0)
+	if err != nil {
+		t.Errorf("The pool returned an error: %s", err.Error())
+	}
+	if c := p.CreatedSinceReset(); c != 2 {
+		t.Errorf("The pool created %d clients since reset but should be 2", c)
+	}
+
+	p.ResetChurnCounters()
+	client, _ := p.Get(context.Background())
+	client.Unhealthy()
+	client.Close()
+	client, _ = p.Get(context.Background())
+	client.Close()
+	if c := p.CreatedSinceReset(); c != 0 {
+		t.Errorf("The pool created %d clients since reset but should be 0", c)
+	}
+	if c := p.ClosedSinceReset(); c != 1 {
+		t.Errorf("The pool closed %d clients since reset but should be 1", c)
+	}
+	if s := p.Stats(); s.Created != 2 {
+		t.Errorf("The cumulative created was %d but should be 2", s.Created)
+	}
+}
+
+func TestFactoryCalls(t *testing.T) {
+	fail := false
+	p, err := New(func() (*grpc.ClientConn, error) {
+		if fail {
+			return nil, errors.New("dial failed")
+		}
+		return dialTestClient()
+	}, 1, 2, 0)
+	if err != nil {
+		t.Errorf("The pool returned an error: %s", err.Error())
+	}
+	defer p.Close()
+
+	client, err := p.Get(context.Background())
+	if err != nil {
+		t.Errorf("Get returned an error: %s", err.Error())
+	}
+	client.Close()
+	if n := p.FactoryCalls(); n != 1 {
+		t.Errorf("The factory was called %d times but should be 1", n)
+	}
+
+	// The placeholder ahead of the returned client gets dialed
+	fail = true
+	if _, err := p.Get(context.Background()); err == nil {
+		t.Errorf("Get should have returned the dial error")
+	}
+	if n := p.FactoryCalls(); n != 2 {
+		t.Errorf("The factory was called %d times but should be 2", n)
+	}
+}
+
+func TestReuseHistogram(t *testing.T) {
+	p, err := New(dialTestClient, 1, 1, 0)
+	if err != nil {
+		t.Errorf("The pool returned an error: %s", err.Error())
+	}
+
+	// Recycle a client after 3 checkouts
+	for i := 0; i < 3; i++ {
+		client, _ := p.Get(context.Background())
+		if i == 2 {
+			client.Unhealthy()
+		}
+		client.Close()
+	}
+	h := p.ReuseHistogram()
+	if len(h) != 3 || h[2] != 1 {
+		t.Errorf("The histogram was %v but should be [0 0 1]", h)
+	}
+
+	p.ResetReuseHistogram()
+	if h := p.ReuseHistogram(); len(h) != 0 {
+		t.Errorf("The histogram was %v but should be empty", h)
+	}
+}
+
+func TestDBStyleStats(t *testing.T) {
+	p, err := New(dialTestClient, 1, 1, 0)
+	if err != nil {
+		t.Errorf("The pool returned an error: %s", err.Error())
+	}
+
+	client, err := p.Get(context.Background())
+	if err != nil {
+		t.Errorf("Get returned an error: %s", err.Error())
+	}
+	go func() {
+		time.Sleep(20 * time.Millisecond)
+		client.Close()
+	}()
+	client2, err := p.Get(context.Background())
+	if err != nil {
+		t.Errorf("Get returned an error: %s", err.Error())
+	}
+
+	s := p.DBStyleStats()
+	if s.MaxOpenConnections != 1 || s.OpenConnections != 1 || s.InUse != 1 || s.Idle != 0 {
+		t.Errorf("The stats gauges were %+v", s)
+	}
+	if s.WaitCount != 1 {
+		t.Errorf("The pool waited %d times but should have waited once", s.WaitCount)
+	}
+	if s.WaitDuration < 10*time.Millisecond {
+		t.Errorf("The pool waited %s but should have waited longer", s.WaitDuration)
+	}
+	client2.Close()
+	if s := p.DBStyleStats(); s.InUse != 0 || s.Idle != 1 {
+		t.Errorf("The stats gauges were %+v", s)
+	}
+}
+
+func TestPressure(t *testing.T) {
+	p, err := New(dialTestClient, 0, 2, 0)
+	if err != nil {
+		t.Errorf("The pool returned an error: %s", err.Error())
+	}
+
+	if pr := p.Pressure(); pr != 0 {
+		t.Errorf("The pool pressure was %v but should be 0", pr)
+	}
+	client, err := p.Get(context.Background())
+	if err != nil {
+		t.Errorf("Get returned an error: %s", err.Error())
+	}
+	client2, err := p.Get(context.Background())
+	if err != nil {
+		t.Errorf("Get returned an error: %s", err.Error())
+	}
+	go p.Get(context.Background())
+	waitForWaiters(p, 1)
+	if pr := p.Pressure(); pr != 1.5 {
+		t.Errorf("The pool pressure was %v but should be 1.5", pr)
+	}
+
+	p.Close()
+	client.Close()
+	client2.Close()
+	if pr := p.Pressure(); pr != 0 {
+		t.Errorf("The pool pressure was %v but should be 0", pr)
+	}
+}
+
+func TestWaitLatency(t *testing.T) {
+	p, err := New(dialTestClient, 1, 1, 0)
+	if err != nil {
+		t.Errorf("The pool returned an error: %s", err.Error())
+	}
+	defer p.Close()
+
+	client, err := p.Get(context.Background())
+	if err != nil {
+		t.Errorf("Get returned an error: %s", err.Error())
+	}
+	go func() {
+		time.Sleep(20 * time.Millisecond)
+		client.Close()
+	}()
+	client, err = p.Get(context.Background())
+	if err != nil {
+		t.Errorf("Get returned an error: %s", err.Error())
+	}
+	client.Close()
+
+	p50, p95, p99 := p.WaitLatency()
+	if p50 != 0 {
+		t.Errorf("The median wait was %s but should be 0", p50)
+	}
+	if p95 < 10*time.Millisecond || p99 < p95 || p99 > 40*time.Millisecond {
+		t.Errorf("The 95th and 99th percentiles were %s and %s", p95, p99)
+	}
+	p.ResetWaitLatency()
+	if _, _, p99 := p.WaitLatency(); p99 != 0 {
+		t.Errorf("The 99th percentile was %s after reset but should be 0", p99)
+	}
+}
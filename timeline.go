@@ -0,0 +1,114 @@
+package grpcpool
+
+import (
+	"sync"
+	"time"
+)
+
+// ConnEventType tells what happened to a client in its timeline
+type ConnEventType int
+
+const (
+	// EventCreated means the client was created by the factory
+	EventCreated ConnEventType = iota
+	// EventCheckedOut means the client was handed out by Get
+	EventCheckedOut
+	// EventReturned means the client was returned to the pool
+	EventReturned
+	// EventRecycled means the client was closed for the reason of the event
+	EventRecycled
+	// EventError means an error was recorded for the client
+	EventError
+)
+
+// String returns a short name for the event type
+func (t ConnEventType) String() string {
+	switch t {
+	case EventCreated:
+		return "created"
+	case EventCheckedOut:
+		return "checked_out"
+	case EventReturned:
+		return "returned"
+	case EventRecycled:
+		return "recycled"
+	case EventError:
+		return "error"
+	default:
+		return "unknown"
+	}
+}
+
+// ConnEvent is an event of the lifecycle of a client
+type ConnEvent struct {
+	Type ConnEventType
+	At   time.Time
+	// Reason is why the client was closed, for EventRecycled
+	Reason CloseReason
+	// Err is the error recorded, for EventError
+	Err error
+}
+
+// WithConnTimeline makes every client record its last max lifecycle events,
+// for Timeline to tell the history of a suspect connection. Recording takes
+// a lock on every event, which is why it's off by default
+func WithConnTimeline(max int) Option {
+	return func(p *Pool) {
+		p.timelineMax = max
+	}
+}
+
+// Timeline returns the last lifecycle events of the client, oldest first, as
+// recorded with WithConnTimeline. It's still available once the client is
+// returned to the pool, through the wrapper Get returned
+func (c *ClientConn) Timeline() []ConnEvent {
+	if c == nil || c.meta == nil || c.meta.timeline == nil {
+		return nil
+	}
+	return c.meta.timeline.snapshot()
+}
+
+// timeline is a bounded ring of the events of a client
+type timeline struct {
+	mu     sync.Mutex
+	events []ConnEvent
+	next   int
+	full   bool
+}
+
+func newTimeline(max int) *timeline {
+	return &timeline{events: make([]ConnEvent, max)}
+}
+
+// add records an event, overwriting the oldest one once the ring is full
+func (t *timeline) add(e ConnEvent) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	t.events[t.next] = e
+	t.next = (t.next + 1) % len(t.events)
+	if t.next == 0 {
+		t.full = true
+	}
+}
+
+// snapshot returns a copy of the events, oldest first
+func (t *timeline) snapshot() []ConnEvent {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	if !t.full {
+		return append([]ConnEvent(nil), t.events[:t.next]...)
+	}
+	events := make([]ConnEvent, 0, len(t.events))
+	events = append(events, t.events[t.next:]...)
+	return append(events, t.events[:t.next]...)
+}
+
+// record adds an event to the timeline of the client, if it has one
+func (m *connMeta) record(typ ConnEventType, reason CloseReason, err error) {
+	if m == nil || m.timeline == nil {
+		return
+	}
+	m.timeline.add(ConnEvent{Type: typ, At: time.Now(), Reason: reason, Err: err})
+}
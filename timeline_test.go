@@ -0,0 +1,55 @@
+package grpcpool
+
+import (
+	"context"
+	"errors"
+	"testing"
+)
+
+func TestWithConnTimeline(t *testing.T) {
+	p, err := New(dialTestClient, 1, 1, 0, WithConnTimeline(4))
+	if err != nil {
+		t.Errorf("The pool returned an error: %s", err.Error())
+	}
+	defer p.Close()
+
+	client, err := p.Get(context.Background())
+	if err != nil {
+		t.Errorf("Get returned an error: %s", err.Error())
+	}
+	errRPC := errors.New("rpc failed")
+	client.RecordError(errRPC)
+	client.Close()
+
+	events := client.Timeline()
+	want := []ConnEventType{EventCreated, EventCheckedOut, EventError, EventReturned}
+	if len(events) != len(want) {
+		t.Errorf("The timeline was %+v", events)
+	}
+	for i := range events {
+		if events[i].Type != want[i] {
+			t.Errorf("The event %d was %s but should be %s", i, events[i].Type, want[i])
+		}
+	}
+	if events[2].Err != errRPC {
+		t.Errorf("The error event was %v but should be %v", events[2].Err, errRPC)
+	}
+
+	// The oldest events are dropped beyond the maximum
+	client, err = p.Get(context.Background())
+	if err != nil {
+		t.Errorf("Get returned an error: %s", err.Error())
+	}
+	client.Unhealthy()
+	client.Close()
+	events = client.Timeline()
+	want = []ConnEventType{EventReturned, EventCheckedOut, EventReturned, EventRecycled}
+	for i := range events {
+		if events[i].Type != want[i] {
+			t.Errorf("The event %d was %s but should be %s", i, events[i].Type, want[i])
+		}
+	}
+	if events[3].Reason != ReasonUnhealthy {
+		t.Errorf("The client was recycled for %s but should be %s", events[3].Reason, ReasonUnhealthy)
+	}
+}
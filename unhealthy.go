@@ -0,0 +1,53 @@
+package grpcpool
+
+import (
+	"errors"
+	"time"
+)
+
+// ErrAllUnhealthy is the error when the last clients checked out of the pool
+// were all unhealthy, as set with WithAllUnhealthy
+var ErrAllUnhealthy = errors.New("grpc pool: all the clients are unhealthy")
+
+// WithAllUnhealthy makes Get fail fast with ErrAllUnhealthy once threshold
+// consecutive checkouts in a row went wrong, so that callers stop cycling
+// through recycling unhealthy clients and failing to redial while the backend
+// is down. A checkout goes wrong when the client is returned marked with
+// ClientConn.Unhealthy, or when dialing a new client fails.
+//
+// Once cooldown has elapsed since the last failure, Get lets calls through
+// again to probe the backend: the first client returned healthy resets the
+// count, while another failure restarts the cooldown
+func WithAllUnhealthy(threshold int, cooldown time.Duration) Option {
+	return func(p *Pool) {
+		p.unhealthyThreshold = threshold
+		p.unhealthyCooldown = cooldown
+	}
+}
+
+// noteHealth counts the consecutive unhealthy checkouts for WithAllUnhealthy
+func (p *Pool) noteHealth(healthy bool) {
+	if p.unhealthyThreshold <= 0 {
+		return
+	}
+	if healthy {
+		p.unhealthyStreak.Store(0)
+		return
+	}
+	p.unhealthyStreak.Add(1)
+	p.lastUnhealthy.Store(time.Now().UnixNano())
+}
+
+// checkAllUnhealthy returns ErrAllUnhealthy if the last checkouts were all
+// unhealthy and the cooldown isn't over
+func (p *Pool) checkAllUnhealthy() error {
+	if p.unhealthyThreshold <= 0 ||
+		p.unhealthyStreak.Load() < int64(p.unhealthyThreshold) {
+		return nil
+	}
+	last := time.Unix(0, p.lastUnhealthy.Load())
+	if time.Since(last) < p.unhealthyCooldown {
+		return ErrAllUnhealthy
+	}
+	return nil
+}
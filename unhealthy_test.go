@@ -0,0 +1,47 @@
+package grpcpool
+
+import (
+	"context"
+	"testing"
+	"time"
+)
+
+func TestWithAllUnhealthy(t *testing.T) {
+	p, err := New(dialTestClient, 2, 2, 0,
+		WithAllUnhealthy(2, 20*time.Millisecond))
+	if err != nil {
+		t.Errorf("The pool returned an error: %s", err.Error())
+	}
+	defer p.Close()
+
+	for i := 0; i < 2; i++ {
+		client, err := p.Get(context.Background())
+		if err != nil {
+			t.Errorf("Get returned an error: %s", err.Error())
+		}
+		client.Unhealthy()
+		client.Close()
+	}
+	if _, err := p.Get(context.Background()); err != ErrAllUnhealthy {
+		t.Errorf("Expected error \"%s\" but got \"%v\"", ErrAllUnhealthy, err)
+	}
+
+	// Once the cooldown is over, a healthy return resets the count
+	time.Sleep(30 * time.Millisecond)
+	client, err := p.Get(context.Background())
+	if err != nil {
+		t.Errorf("Get returned an error: %s", err.Error())
+	}
+	client.Close()
+	client, err = p.Get(context.Background())
+	if err != nil {
+		t.Errorf("Get returned an error: %s", err.Error())
+	}
+	client.Unhealthy()
+	client.Close()
+	client, err = p.Get(context.Background())
+	if err != nil {
+		t.Errorf("Get returned an error: %s", err.Error())
+	}
+	client.Close()
+}
@@ -0,0 +1,106 @@
+package grpcpool
+
+import (
+	"errors"
+	"fmt"
+
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/connectivity"
+)
+
+// ErrDrift is the error when Verify finds the pool in an inconsistent state
+var ErrDrift = errors.New("grpc pool: the pool state drifted")
+
+// Verify checks the invariants of the clients waiting in the pool, and
+// repairs what it can, returning an error wrapping ErrDrift describing what
+// was found, or nil:
+//
+//   - a client held twice by the pool, or waiting in it while checked out
+//     (with WithCheckoutTracking), is replaced with a placeholder
+//   - a client waiting in the pool while already shut down is replaced with
+//     a placeholder, for Get to dial a new one
+//   - with WithCheckoutTracking, more clients waiting and checked out than
+//     the capacity of the pool is reported, but can't be repaired
+//   - fewer slots than the capacity of the pool, counting the clients
+//     waiting, checked out, in transit (e.g. being dialed) and held back by
+//     WithGrowthLimit, is reported, and topped up with placeholders
+//
+// The pool is locked while it's verified, Get and Close wait for it
+func (p *Pool) Verify() error {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	// Get takes clients out of the pool under waitMu, the other takers under
+	// the read lock: holding both freezes the pool content
+	p.waitMu.Lock()
+	defer p.waitMu.Unlock()
+
+	if p.clients == nil {
+		return ErrClosed
+	}
+
+	var out map[*grpc.ClientConn]bool
+	if p.outstanding != nil {
+		p.outMu.Lock()
+		out = make(map[*grpc.ClientConn]bool, len(p.outstanding))
+		for conn := range p.outstanding {
+			out[conn] = true
+		}
+		p.outMu.Unlock()
+	}
+
+	var (
+		errs []error
+		seen = make(map[*grpc.ClientConn]bool)
+	)
+	idle := 0
+	for i := len(p.clients); i > 0; i-- {
+		// Never block with the pool locked, should a client be taken
+		// meanwhile anyway
+		var client ClientConn
+		select {
+		case client = <-p.clients:
+		default:
+		}
+		if client.pool == nil {
+			break
+		}
+		idle++
+		if conn := client.ClientConn; conn != nil {
+			switch {
+			case seen[conn]:
+				errs = append(errs, fmt.Errorf("client %d is held twice", client.meta.id))
+				client = ClientConn{pool: p}
+			case out[conn]:
+				errs = append(errs, fmt.Errorf("client %d is idle while checked out", client.meta.id))
+				client = ClientConn{pool: p}
+			case conn.GetState() == connectivity.Shutdown:
+				errs = append(errs, fmt.Errorf("client %d is idle while shut down", client.meta.id))
+				client = ClientConn{pool: p}
+			}
+			seen[conn] = true
+		}
+		p.clients <- client
+	}
+	if out != nil && idle+len(out) > cap(p.clients) {
+		errs = append(errs, fmt.Errorf("%d clients are idle and %d checked out, beyond the capacity of %d",
+			idle, len(out), cap(p.clients)))
+	}
+	// The slots leave transit to be checked out or held back without the
+	// pool lock: loading the ones in transit first may count a slot twice,
+	// but never miss it
+	held := p.inTransit.Load()
+	held += int64(idle) + p.checkedOut.Load() + p.reserved.Load()
+	if lost := int64(cap(p.clients)) - held; lost > 0 {
+		errs = append(errs, fmt.Errorf("%d slots are lost", lost))
+		for i := int64(0); i < lost; i++ {
+			if !p.sendLocked(ClientConn{pool: p}) {
+				break
+			}
+		}
+	}
+
+	if len(errs) == 0 {
+		return nil
+	}
+	return fmt.Errorf("%w: %w", ErrDrift, errors.Join(errs...))
+}
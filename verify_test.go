@@ -0,0 +1,81 @@
+package grpcpool
+
+import (
+	"context"
+	"errors"
+	"testing"
+)
+
+func TestVerify(t *testing.T) {
+	p, err := New(dialTestClient, 2, 2, 0)
+	if err != nil {
+		t.Errorf("The pool returned an error: %s", err.Error())
+	}
+	defer p.Close()
+
+	if err := p.Verify(); err != nil {
+		t.Errorf("Verify returned an error: %s", err.Error())
+	}
+
+	// Closing a copy of the wrapper puts the client back twice
+	client, err := p.Get(context.Background())
+	if err != nil {
+		t.Errorf("Get returned an error: %s", err.Error())
+	}
+	copied := *client
+	client.Close()
+	p.Get(context.Background())
+	copied.Close()
+	if err := p.Verify(); !errors.Is(err, ErrDrift) {
+		t.Errorf("Expected error \"%s\" but got \"%v\"", ErrDrift, err)
+	}
+	if err := p.Verify(); err != nil {
+		t.Errorf("Verify should have repaired the pool but returned: %s", err.Error())
+	}
+
+	// A client shut down behind the pool back is replaced
+	client, err = p.Get(context.Background())
+	if err != nil {
+		t.Errorf("Get returned an error: %s", err.Error())
+	}
+	client.ClientConn.Close()
+	client.Close()
+	if err := p.Verify(); !errors.Is(err, ErrDrift) {
+		t.Errorf("Expected error \"%s\" but got \"%v\"", ErrDrift, err)
+	}
+	if err := p.Verify(); err != nil {
+		t.Errorf("Verify should have repaired the pool but returned: %s", err.Error())
+	}
+}
+
+func TestVerifyLostSlot(t *testing.T) {
+	p, err := New(dialTestClient, 1, 2, 0)
+	if err != nil {
+		t.Errorf("The pool returned an error: %s", err.Error())
+	}
+	defer p.Close()
+
+	client, err := p.Get(context.Background())
+	if err != nil {
+		t.Errorf("Get returned an error: %s", err.Error())
+	}
+	if err := p.Verify(); err != nil {
+		t.Errorf("Verify returned an error: %s", err.Error())
+	}
+
+	// A slot vanishing from the pool, e.g. through a bug, is topped up
+	<-p.clients
+	if err := p.Verify(); !errors.Is(err, ErrDrift) {
+		t.Errorf("Expected error \"%s\" but got \"%v\"", ErrDrift, err)
+	}
+	if a := p.Available(); a != 1 {
+		t.Errorf("The pool available was %d but should be 1", a)
+	}
+	client.Close()
+	if a := p.Available(); a != 2 {
+		t.Errorf("The pool available was %d but should be 2", a)
+	}
+	if err := p.Verify(); err != nil {
+		t.Errorf("Verify returned an error: %s", err.Error())
+	}
+}
@@ -0,0 +1,58 @@
+package grpcpool
+
+import (
+	"math"
+	"time"
+)
+
+// WaitLatency returns the median, 95th and 99th percentiles of the time Get
+// calls waited for a client since the pool was created or the last
+// ResetWaitLatency, the calls served right away counting as no wait. The
+// waits are counted in power of two buckets to bound the memory used, so the
+// percentiles are estimates interpolated within their bucket
+func (p *Pool) WaitLatency() (p50, p95, p99 time.Duration) {
+	q := p.waits.quantiles(p.counters.noWait.Load(), 0.5, 0.95, 0.99)
+	return time.Duration(q[0]), time.Duration(q[1]), time.Duration(q[2])
+}
+
+// ResetWaitLatency restarts the percentiles of WaitLatency from no wait
+func (p *Pool) ResetWaitLatency() {
+	p.waits.reset()
+	p.counters.noWait.Store(0)
+}
+
+// quantiles estimates the values at the quantiles qs of the recorded values,
+// plus zeros more values of 0, interpolating linearly within the buckets
+func (h *histogram) quantiles(zeros int64, qs ...float64) []int64 {
+	h.mu.Lock()
+	buckets := h.buckets
+	h.mu.Unlock()
+
+	buckets[0] += int(zeros)
+	total := 0
+	for _, n := range buckets {
+		total += n
+	}
+	values := make([]int64, len(qs))
+	if total == 0 {
+		return values
+	}
+	for j, q := range qs {
+		rank := q * float64(total)
+		seen := 0
+		for i, n := range buckets {
+			if n == 0 || float64(seen+n) < rank {
+				seen += n
+				continue
+			}
+			if i > 0 {
+				// Bucket i holds the values from 2^(i-1) to 2^i-1
+				lo := float64(uint64(1) << (i - 1))
+				hi := math.Min(2*lo-1, math.MaxInt64)
+				values[j] = int64(lo + (rank-float64(seen))/float64(n)*(hi-lo))
+			}
+			break
+		}
+	}
+	return values
+}
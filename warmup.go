@@ -0,0 +1,170 @@
+package grpcpool
+
+import (
+	"context"
+	"errors"
+	"sync"
+	"time"
+
+	"google.golang.org/grpc"
+)
+
+// WarmupErrorPolicy tells New what to do when dialing one of the initial
+// clients fails
+type WarmupErrorPolicy int
+
+const (
+	// AbortOnError makes New close the clients already created and return
+	// the dial error. It's the default
+	AbortOnError WarmupErrorPolicy = iota
+	// SkipOnError makes New put a placeholder in place of the client that
+	// failed, to be dialed by Get, so the pool starts with fewer warm clients
+	SkipOnError
+	// RetryOnError makes New retry a failed dial a few times with an
+	// exponential backoff, before aborting like AbortOnError
+	RetryOnError
+)
+
+const (
+	// warmupRetries is the number of dials retried by RetryOnError
+	warmupRetries = 3
+	// warmupBackoff is the wait before the first dial retried by
+	// RetryOnError, doubled on each retry
+	warmupBackoff = 100 * time.Millisecond
+)
+
+// WithWarmupErrorPolicy sets how New handles the failure to dial one of the
+// initial clients
+func WithWarmupErrorPolicy(policy WarmupErrorPolicy) Option {
+	return func(p *Pool) {
+		p.warmupPolicy = policy
+	}
+}
+
+// fill puts init clients dialed with the factory in the pool, then
+// placeholders up to capacity, handling the dial errors according to the
+// warmup policy. When aborting, the clients already created are closed
+func (p *Pool) fill(init, capacity int) error {
+	if p.growthLimit > 0 && p.growthLimit < capacity {
+		// The slots beyond the growth limit are held back until a burst
+		init = min(init, p.growthLimit)
+		p.reserved.Store(int64(capacity - p.growthLimit))
+		capacity = p.growthLimit
+	}
+	for i := 0; i < init; i++ {
+		c, err := p.dial()
+		for retry, backoff := 0, warmupBackoff; err != nil &&
+			p.warmupPolicy == RetryOnError && retry < warmupRetries; retry++ {
+			time.Sleep(backoff)
+			backoff *= 2
+			c, err = p.dial()
+		}
+		if err != nil && p.warmupPolicy != SkipOnError {
+			for len(p.clients) > 0 {
+				p.closeConn((<-p.clients).ClientConn)
+			}
+			return err
+		}
+
+		p.clients <- c
+	}
+	// Fill the rest of the pool with empty clients
+	for i := 0; i < capacity-init; i++ {
+		p.clients <- ClientConn{
+			pool: p,
+		}
+	}
+	return nil
+}
+
+// WarmupAsync prepares the clients waiting in the pool in the background:
+// each placeholder is dialed with the factory, then warm runs concurrently on
+// every client, e.g. to perform a first RPC. The clients for which warm fails
+// are closed and replaced with placeholders. Clients are unavailable to Get
+// while being warmed up. While the pool is draining, the placeholders are
+// left as they are, only the warm clients are warmed up.
+//
+// The returned channel receives, once every client is done, nil or the
+// errors of the dials and warmups that failed joined together, then it's
+// closed. Warmups still running when ctx is done are waited for, but no new
+// one is started
+func (p *Pool) WarmupAsync(ctx context.Context,
+	warm func(*grpc.ClientConn) error) <-chan error {
+	done := make(chan error, 1)
+
+	clients := p.getClients()
+	if clients == nil {
+		done <- ErrClosed
+		close(done)
+		return done
+	}
+
+	var (
+		wg   sync.WaitGroup
+		mu   sync.Mutex
+		errs []error
+	)
+	fail := func(err error) {
+		mu.Lock()
+		errs = append(errs, err)
+		mu.Unlock()
+	}
+	for i := len(clients); i > 0 && ctx.Err() == nil; i-- {
+		client := p.takeIdle()
+		if client.pool == nil {
+			break
+		}
+		if client.ClientConn == nil && p.draining() != DrainNone {
+			// No client is dialed while draining
+			p.putBack(client)
+			continue
+		}
+
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+
+			if client.ClientConn == nil {
+				var err error
+				if client, err = p.dial(); err != nil {
+					fail(err)
+					p.putBack(ClientConn{pool: p})
+					return
+				}
+			}
+			if err := warm(client.ClientConn); err != nil {
+				fail(err)
+				p.closeConn(client.ClientConn)
+				p.putBack(ClientConn{pool: p})
+				return
+			}
+			p.putBack(client)
+		}()
+	}
+
+	go func() {
+		wg.Wait()
+		done <- errors.Join(errs...)
+		close(done)
+	}()
+	return done
+}
+
+// takeIdle takes the next client, warm or not, out of the pool without
+// waiting, returning a zero ClientConn if there's none. It holds the read
+// lock, like sweep, so that Verify never sees the pool changing
+func (p *Pool) takeIdle() ClientConn {
+	p.mu.RLock()
+	defer p.mu.RUnlock()
+
+	if p.clients == nil {
+		return ClientConn{}
+	}
+	select {
+	case client := <-p.clients:
+		p.inTransit.Add(1)
+		return client
+	default:
+		return ClientConn{}
+	}
+}
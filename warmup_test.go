@@ -0,0 +1,83 @@
+package grpcpool
+
+import (
+	"context"
+	"errors"
+	"sync/atomic"
+	"testing"
+
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/connectivity"
+)
+
+func TestWarmupAsync(t *testing.T) {
+	p, err := New(dialTestClient, 1, 3, 0)
+	if err != nil {
+		t.Errorf("The pool returned an error: %s", err.Error())
+	}
+	defer p.Close()
+
+	var calls atomic.Int32
+	errWarm := errors.New("warmup failed")
+	done := p.WarmupAsync(context.Background(), func(*grpc.ClientConn) error {
+		if calls.Add(1) == 1 {
+			return errWarm
+		}
+		return nil
+	})
+	if err := <-done; !errors.Is(err, errWarm) {
+		t.Errorf("Expected error \"%s\" but got \"%v\"", errWarm, err)
+	}
+
+	if c := calls.Load(); c != 3 {
+		t.Errorf("The warmup ran %d times but should run 3", c)
+	}
+	if a := p.Available(); a != 3 {
+		t.Errorf("The pool available was %d but should be 3", a)
+	}
+	if s := p.Stats(); s.Created != 3 || s.Closed != 1 {
+		t.Errorf("One client should be recycled after warmup, stats were %+v", s)
+	}
+}
+
+func TestWarmupErrorPolicy(t *testing.T) {
+	errDial := errors.New("dial failed")
+	// failSecond fails the second dial only
+	failSecond := func(conns *[]*grpc.ClientConn) Factory {
+		var calls atomic.Int32
+		return func() (*grpc.ClientConn, error) {
+			if calls.Add(1) == 2 {
+				return nil, errDial
+			}
+			conn, err := dialTestClient()
+			*conns = append(*conns, conn)
+			return conn, err
+		}
+	}
+
+	var conns []*grpc.ClientConn
+	if _, err := New(failSecond(&conns), 3, 3, 0); err != errDial {
+		t.Errorf("Expected error \"%s\" but got \"%v\"", errDial, err)
+	}
+	if s := conns[0].GetState(); s != connectivity.Shutdown {
+		t.Errorf("The connection state was %s but should be %s", s, connectivity.Shutdown)
+	}
+
+	p, err := New(failSecond(&conns), 3, 3, 0, WithWarmupErrorPolicy(SkipOnError))
+	if err != nil {
+		t.Errorf("The pool returned an error: %s", err.Error())
+	}
+	if s := p.Stats(); s.Created != 2 || s.Available != 3 {
+		t.Errorf("The stats were %+v", s)
+	}
+	p.Close()
+
+	p, err = New(failSecond(&conns), 3, 3, 0, WithWarmupErrorPolicy(RetryOnError))
+	if err != nil {
+		t.Errorf("The pool returned an error: %s", err.Error())
+	}
+	if s := p.Stats(); s.Created != 3 || s.DialErrors != 1 {
+		t.Errorf("The stats were %+v", s)
+	}
+	p.Close()
+}
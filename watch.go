@@ -0,0 +1,79 @@
+package grpcpool
+
+import (
+	"errors"
+
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/connectivity"
+)
+
+// ErrConnNotFound is the error when no client of the pool has the ID asked
+var ErrConnNotFound = errors.New("grpc pool: client not found")
+
+// WatchConn calls cb on every state change of the client with the ID
+// connID, the ID of its ConnInfo, e.g. to log in detail what a single flaky
+// client goes through without watching them all. The watch ends once the
+// client is closed, cb being called a last time for its move to Shutdown, or
+// when the pool is closed. cb is called from a goroutine of its own and
+// mustn't block for long, state changes happening meanwhile being missed.
+//
+// The client is looked up among the idle ones and, with WithCheckoutTracking
+// or WithStrictLifecycle, the ones checked out. WatchConn returns
+// ErrConnNotFound if it's in neither
+func (p *Pool) WatchConn(connID uint64, cb func(from, to connectivity.State)) error {
+	conn := p.findConn(connID)
+	if conn == nil {
+		if p.IsClosed() {
+			return ErrClosed
+		}
+		return ErrConnNotFound
+	}
+
+	// The watch is registered under the read lock, so that close waits for
+	// it unless it went first
+	p.mu.RLock()
+	defer p.mu.RUnlock()
+
+	if p.clients == nil {
+		return ErrClosed
+	}
+	p.wg.Add(1)
+	go func() {
+		defer p.wg.Done()
+
+		state := conn.GetState()
+		for state != connectivity.Shutdown {
+			if !conn.WaitForStateChange(p.ctx, state) {
+				return
+			}
+			next := conn.GetState()
+			cb(state, next)
+			state = next
+		}
+	}()
+	return nil
+}
+
+// findConn returns the client conn with the ID id, checked out or idle, or
+// nil if there's none
+func (p *Pool) findConn(id uint64) *grpc.ClientConn {
+	if p.outstanding != nil {
+		p.outMu.Lock()
+		for conn, out := range p.outstanding {
+			if out.meta.id == id {
+				p.outMu.Unlock()
+				return conn
+			}
+		}
+		p.outMu.Unlock()
+	}
+
+	var found *grpc.ClientConn
+	p.sweep(func(client ClientConn) ClientConn {
+		if client.ClientConn != nil && client.meta.id == id {
+			found = client.ClientConn
+		}
+		return client
+	})
+	return found
+}
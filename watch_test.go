@@ -0,0 +1,60 @@
+package grpcpool
+
+import (
+	"context"
+	"sync"
+	"testing"
+	"time"
+
+	"google.golang.org/grpc/connectivity"
+)
+
+func TestWatchConn(t *testing.T) {
+	p, err := New(newTestServer(t), 1, 1, 0, WithCheckoutTracking(false))
+	if err != nil {
+		t.Errorf("The pool returned an error: %s", err.Error())
+	}
+	defer p.Close()
+
+	if err := p.WatchConn(42, func(_, _ connectivity.State) {}); err != ErrConnNotFound {
+		t.Errorf("Expected error \"%s\" but got \"%v\"", ErrConnNotFound, err)
+	}
+
+	client, err := p.Get(context.Background())
+	if err != nil {
+		t.Errorf("Get returned an error: %s", err.Error())
+	}
+	var mu sync.Mutex
+	var states []connectivity.State
+	done := make(chan struct{})
+	err = p.WatchConn(p.CheckedOut()[0].ID, func(_, to connectivity.State) {
+		mu.Lock()
+		defer mu.Unlock()
+		states = append(states, to)
+		if to == connectivity.Shutdown {
+			close(done)
+		}
+	})
+	if err != nil {
+		t.Errorf("WatchConn returned an error: %s", err.Error())
+	}
+
+	client.Connect()
+	deadline := time.Now().Add(time.Second)
+	for client.GetState() != connectivity.Ready && time.Now().Before(deadline) {
+		time.Sleep(5 * time.Millisecond)
+	}
+	client.Unhealthy()
+	client.Close()
+
+	select {
+	case <-done:
+	case <-time.After(time.Second):
+		t.Fatalf("The watch didn't see the client recycled")
+	}
+	mu.Lock()
+	defer mu.Unlock()
+	if states[len(states)-1] != connectivity.Shutdown {
+		t.Errorf("The states seen were %v", states)
+	}
+}